@@ -1,11 +1,23 @@
 package main
 
 import (
+	"context"
+	"english-learning-app/internal/account"
 	"english-learning-app/internal/config"
 	"english-learning-app/internal/database"
 	"english-learning-app/internal/handlers"
+	"english-learning-app/internal/jobs"
+	"english-learning-app/internal/keys"
+	"english-learning-app/internal/leaderboard"
+	"english-learning-app/internal/leagues"
+	"english-learning-app/internal/mailer"
 	"english-learning-app/internal/middleware"
+	"english-learning-app/internal/models"
+	"english-learning-app/internal/oidc"
+	"english-learning-app/internal/points"
+	"english-learning-app/internal/throttle"
 	"log"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -36,6 +48,64 @@ func main() {
 		log.Fatal("Failed to seed database:", err)
 	}
 
+	// Record balances earned before the points ledger existed
+	if err := points.Backfill(database.DB); err != nil {
+		log.Fatal("Failed to backfill points ledger:", err)
+	}
+
+	// Setup token signing keys
+	if err := keys.Setup(cfg); err != nil {
+		log.Fatal("Failed to setup signing keys:", err)
+	}
+
+	// Setup mailer
+	if err := mailer.Setup(cfg); err != nil {
+		log.Fatal("Failed to setup mailer:", err)
+	}
+
+	// Setup login throttling
+	if err := throttle.Setup(cfg, database.DB); err != nil {
+		log.Fatal("Failed to setup login throttling:", err)
+	}
+
+	// Setup identity providers
+	if err := oidc.Setup(cfg); err != nil {
+		log.Fatal("Failed to setup identity providers:", err)
+	}
+
+	// Background jobs
+	scheduler := jobs.NewScheduler()
+	scheduler.Every("account-purge", time.Duration(cfg.Account.PurgeIntervalMinutes)*time.Minute, func(ctx context.Context) error {
+		purged, err := account.PurgeDue(database.DB, time.Now())
+		if purged > 0 {
+			log.Printf("Purged %d deleted accounts", purged)
+		}
+		return err
+	})
+	scheduler.Every("guest-cleanup", time.Duration(cfg.Account.PurgeIntervalMinutes)*time.Minute, func(ctx context.Context) error {
+		purged, err := account.PurgeExpiredGuests(database.DB, time.Now())
+		if purged > 0 {
+			log.Printf("Purged %d expired guest accounts", purged)
+		}
+		return err
+	})
+	scheduler.Every("leaderboard-sync", time.Duration(cfg.Leaderboard.SyncIntervalMinutes)*time.Minute, func(ctx context.Context) error {
+		drifted, err := leaderboard.Sync(database.DB, time.Now())
+		if drifted > 0 {
+			log.Printf("Repaired leaderboard scores of %d users", drifted)
+		}
+		return err
+	})
+	leaguePolicy := leagues.PolicyFromConfig(cfg)
+	scheduler.Every("league-close", time.Duration(cfg.League.CloseIntervalMinutes)*time.Minute, func(ctx context.Context) error {
+		closed, err := leagues.CloseEndedWeeks(database.DB, time.Now(), leaguePolicy)
+		if closed > 0 {
+			log.Printf("Closed %d weekly leagues", closed)
+		}
+		return err
+	})
+	scheduler.Start(context.Background())
+
 	// Setup Gin router
 	router := gin.Default()
 
@@ -51,14 +121,22 @@ func main() {
 	router.POST("/api/auth/register", handlers.Register)
 	router.POST("/api/auth/login", handlers.Login)
 	router.POST("/api/auth/refresh", handlers.RefreshToken)
+	router.POST("/api/auth/guest", handlers.CreateGuest)
+	router.POST("/api/auth/mfa/verify", handlers.VerifyMFA)
+	router.GET("/api/auth/oidc/providers", handlers.GetOIDCProviders)
+	router.GET("/api/auth/oidc/:provider/start", handlers.StartOIDCLogin)
+	router.POST("/api/auth/oidc/:provider/callback", handlers.OIDCCallback)
+	router.POST("/api/auth/verify-email", handlers.VerifyEmail)
+	router.POST("/api/auth/forgot-password", handlers.ForgotPassword)
+	router.POST("/api/auth/reset-password", handlers.ResetPassword)
 
-	// Protected routes
+	// Protected routes, open to guest accounts
 	protected := router.Group("/api")
-	protected.Use(middleware.AuthMiddleware(cfg))
+	protected.Use(middleware.AuthMiddleware(cfg), middleware.RequireSession())
 	{
-		// User routes
 		protected.GET("/user/profile", handlers.GetProfile)
-		protected.PUT("/user/profile", handlers.UpdateProfile)
+		protected.POST("/auth/guest/upgrade", handlers.UpgradeGuest)
+		protected.POST("/auth/logout", handlers.Logout)
 
 		// Levels and topics
 		protected.GET("/levels", handlers.GetLevels)
@@ -69,38 +147,138 @@ func main() {
 		// Progress
 		protected.GET("/progress", handlers.GetUserProgress)
 		protected.POST("/progress/complete", handlers.CompleteTopic)
+		protected.GET("/user/level", handlers.GetLevelProgress)
+		protected.GET("/user/level/history", handlers.GetLevelHistory)
+		protected.POST("/user/level/history/:id/ack", handlers.AcknowledgeLevelUp)
+
+		// Placement test
+		protected.GET("/placement", handlers.GetPlacementTest)
+		protected.POST("/placement", handlers.StartPlacementTest)
+		protected.POST("/placement/:id/answer", handlers.AnswerPlacementQuestion)
 
 		// Exercises
 		protected.GET("/exercises/:id", handlers.GetExercise)
 		protected.POST("/exercises/:id/attempt", handlers.SubmitExercise)
 
+		// Quiz sessions
+		protected.POST("/quizzes", handlers.StartQuiz)
+		protected.GET("/quizzes/:id", handlers.GetQuiz)
+		protected.GET("/quizzes/:id/next", handlers.GetQuizQuestion)
+		protected.POST("/quizzes/:id/answer", handlers.AnswerQuiz)
+		protected.POST("/quizzes/:id/finish", handlers.FinishQuiz)
+
+		// Achievements
+		protected.GET("/achievements", handlers.GetAchievements)
+
+		// Daily goal and streak
+		protected.GET("/user/daily", handlers.GetDailyStatus)
+		protected.PUT("/user/daily-goal", handlers.UpdateDailyGoal)
+		protected.POST("/user/streak/freeze", handlers.BuyStreakFreeze)
+	}
+
+	// Routes that need a full account
+	registered := protected.Group("")
+	registered.Use(middleware.RequireRegistered())
+	{
+		// User routes
+		registered.PUT("/user/profile", handlers.UpdateProfile)
+		registered.POST("/auth/resend-verification", handlers.ResendVerification)
+		registered.PUT("/user/password", handlers.ChangePassword)
+		registered.GET("/user/export", handlers.ExportAccountData)
+		registered.DELETE("/user/account", handlers.DeleteAccount)
+		registered.POST("/user/account/cancel-deletion", handlers.CancelAccountDeletion)
+
+		// Two-factor authentication
+		registered.POST("/user/mfa/totp/setup", handlers.SetupTOTP)
+		registered.POST("/user/mfa/totp/confirm", handlers.ConfirmTOTP)
+		registered.POST("/user/mfa/totp/disable", handlers.DisableTOTP)
+		registered.POST("/user/mfa/recovery-codes", handlers.RegenerateRecoveryCodes)
+
+		// Linked identity providers
+		registered.GET("/user/identities", handlers.GetIdentities)
+		registered.POST("/user/identities/:provider/start", handlers.StartOIDCLink)
+		registered.DELETE("/user/identities/:id", handlers.UnlinkIdentity)
+
+		// Personal access tokens
+		registered.GET("/user/tokens", handlers.GetAccessTokens)
+		registered.POST("/user/tokens", handlers.CreateAccessToken)
+		registered.DELETE("/user/tokens/:id", handlers.RevokeAccessToken)
+
+		// Sessions
+		registered.GET("/user/sessions", handlers.GetSessions)
+		registered.DELETE("/user/sessions/:id", handlers.RevokeSession)
+		registered.POST("/auth/logout-all", handlers.LogoutAll)
+
 		// AI Chat
-		protected.GET("/chat/sessions", handlers.GetChatSessions)
-		protected.POST("/chat/sessions", handlers.CreateChatSession)
-		protected.GET("/chat/sessions/:id/messages", handlers.GetChatMessages)
-		protected.POST("/chat/sessions/:id/messages", handlers.SendMessage)
+		aiChat := middleware.RequireVerifiedEmail(cfg, "ai_chat")
+		registered.GET("/chat/sessions", aiChat, handlers.GetChatSessions)
+		registered.POST("/chat/sessions", aiChat, handlers.CreateChatSession)
+		registered.GET("/chat/sessions/:id/messages", aiChat, handlers.GetChatMessages)
+		registered.POST("/chat/sessions/:id/messages", aiChat, handlers.SendMessage)
 
 		// Leaderboard
-		protected.GET("/leaderboard", handlers.GetLeaderboard)
+		registered.GET("/leaderboard", middleware.RequireVerifiedEmail(cfg, "leaderboard"), handlers.GetLeaderboard)
+
+		// Weekly leagues
+		registered.GET("/leagues/current", handlers.GetCurrentLeague)
+		registered.GET("/leagues/history", handlers.GetLeagueHistory)
+
+		// Friends and classrooms
+		registered.GET("/friends", handlers.GetFriends)
+		registered.POST("/friends/:id", handlers.AddFriend)
+		registered.DELETE("/friends/:id", handlers.RemoveFriend)
+		registered.GET("/classrooms", handlers.GetClassrooms)
+		registered.POST("/classrooms", middleware.RequirePermission(models.PermClassesManage), handlers.CreateClassroom)
+		registered.POST("/classrooms/join", handlers.JoinClassroom)
+		registered.DELETE("/classrooms/:id/membership", handlers.LeaveClassroom)
 	}
 
-	// Admin routes
+	// Admin routes, also reachable with scoped personal access tokens
 	admin := router.Group("/api/admin")
-	admin.Use(middleware.AuthMiddleware(cfg), middleware.AdminMiddleware())
+	admin.Use(middleware.AuthMiddleware(cfg))
 	{
-		admin.POST("/levels", handlers.CreateLevel)
-		admin.PUT("/levels/:id", handlers.UpdateLevel)
-		admin.DELETE("/levels/:id", handlers.DeleteLevel)
+		content := middleware.RequirePermission(models.PermContentWrite)
+		deleteContent := middleware.RequirePermission(models.PermContentDelete)
 
-		admin.POST("/topics", handlers.CreateTopic)
-		admin.PUT("/topics/:id", handlers.UpdateTopic)
-		admin.DELETE("/topics/:id", handlers.DeleteTopic)
+		admin.POST("/levels", content, handlers.CreateLevel)
+		admin.PUT("/levels/:id", content, handlers.UpdateLevel)
+		admin.DELETE("/levels/:id", deleteContent, handlers.DeleteLevel)
 
-		admin.POST("/exercises", handlers.CreateExercise)
-		admin.PUT("/exercises/:id", handlers.UpdateExercise)
-		admin.DELETE("/exercises/:id", handlers.DeleteExercise)
+		admin.POST("/topics", content, handlers.CreateTopic)
+		admin.PUT("/topics/:id", content, handlers.UpdateTopic)
+		admin.DELETE("/topics/:id", deleteContent, handlers.DeleteTopic)
+
+		admin.POST("/exercises", content, handlers.CreateExercise)
+		admin.PUT("/exercises/:id", content, handlers.UpdateExercise)
+		admin.DELETE("/exercises/:id", deleteContent, handlers.DeleteExercise)
+
+		admin.GET("/achievements", content, handlers.ListAchievements)
+		admin.POST("/achievements", content, handlers.CreateAchievement)
+		admin.PUT("/achievements/:id", content, handlers.UpdateAchievement)
+		admin.DELETE("/achievements/:id", deleteContent, handlers.DeleteAchievement)
+
+		readUsers := middleware.RequirePermission(models.PermUsersRead)
+		manageRoles := middleware.RequirePermission(models.PermRolesManage)
+
+		admin.GET("/users/:id/roles", readUsers, handlers.GetUserRoles)
+		admin.POST("/users/:id/roles", manageRoles, handlers.GrantRole)
+		admin.DELETE("/users/:id/roles/:role", manageRoles, handlers.RevokeRole)
+
+		managePoints := middleware.RequirePermission(models.PermPointsManage)
+
+		admin.GET("/users/:id/points", readUsers, handlers.GetPointsLedger)
+		admin.POST("/users/:id/points", managePoints, handlers.AdjustPoints)
+		admin.POST("/users/:id/points/recompute", managePoints, handlers.RecomputePoints)
+
+		admin.GET("/leaderboard/consistency", readUsers, handlers.CheckLeaderboard)
+		admin.POST("/leaderboard/rebuild", managePoints, handlers.RebuildLeaderboard)
+
+		admin.GET("/security-events", middleware.RequirePermission(models.PermSecurityRead), handlers.GetSecurityEvents)
 	}
 
+	// Public signing keys
+	router.GET("/.well-known/jwks.json", handlers.GetJWKS)
+
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
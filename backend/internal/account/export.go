@@ -0,0 +1,196 @@
+package account
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"english-learning-app/internal/models"
+	"io"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// The models carry relation fields without pointers, which would show up as
+// empty objects in the export. These wrappers shadow them with nil pointers.
+type exportedProgress struct {
+	models.UserProgress
+	User *struct{} `json:"user,omitempty"`
+}
+
+type exportedAttempt struct {
+	models.ExerciseAttempt
+	User *struct{} `json:"user,omitempty"`
+	// Only the question, so the export does not give away the answer key
+	Exercise exportedExercise `json:"exercise"`
+}
+
+type exportedExercise struct {
+	ID       uuid.UUID `json:"id"`
+	Question string    `json:"question"`
+}
+
+type exportedChatMessage struct {
+	models.ChatMessage
+	Session *struct{} `json:"session,omitempty"`
+}
+
+type exportedChatSession struct {
+	models.ChatSession
+	User     *struct{}             `json:"user,omitempty"`
+	Messages []exportedChatMessage `json:"messages"`
+}
+
+// Export writes a zip archive with one JSON document per kind of personal
+// data held about the user.
+func Export(db *gorm.DB, userID uuid.UUID, w io.Writer) error {
+	var user models.User
+	if err := db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return err
+	}
+	roles, err := models.LoadUserRoles(db, &user)
+	if err != nil {
+		return err
+	}
+
+	var progress []models.UserProgress
+	if err := db.Preload("Topic").Where("user_id = ?", userID).Order("created_at").Find(&progress).Error; err != nil {
+		return err
+	}
+
+	var attempts []models.ExerciseAttempt
+	if err := db.Preload("Exercise").Where("user_id = ?", userID).Order("attempted_at").Find(&attempts).Error; err != nil {
+		return err
+	}
+
+	var chatSessions []models.ChatSession
+	if err := db.Preload("Messages", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at")
+	}).Where("user_id = ?", userID).Order("created_at").Find(&chatSessions).Error; err != nil {
+		return err
+	}
+
+	var achievements []models.Achievement
+	if err := db.Model(&user).Association("Achievements").Find(&achievements); err != nil {
+		return err
+	}
+
+	var pointsEntries []models.PointsEntry
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&pointsEntries).Error; err != nil {
+		return err
+	}
+
+	var streak []models.UserStreak
+	if err := db.Where("user_id = ?", userID).Find(&streak).Error; err != nil {
+		return err
+	}
+
+	var friendships []models.Friendship
+	if err := db.Where("user_id = ? OR friend_id = ?", userID, userID).Order("created_at").Find(&friendships).Error; err != nil {
+		return err
+	}
+
+	var classrooms []models.ClassroomMember
+	if err := db.Where("user_id = ?", userID).Order("joined_at").Find(&classrooms).Error; err != nil {
+		return err
+	}
+
+	var leagues []models.LeagueMembership
+	if err := db.Where("user_id = ?", userID).Order("week_start").Find(&leagues).Error; err != nil {
+		return err
+	}
+
+	var levelHistory []models.UserLevelHistory
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&levelHistory).Error; err != nil {
+		return err
+	}
+
+	var placementTests []models.PlacementTest
+	if err := db.Preload("Answers", func(db *gorm.DB) *gorm.DB {
+		return db.Order("answered_at")
+	}).Where("user_id = ?", userID).Order("started_at").Find(&placementTests).Error; err != nil {
+		return err
+	}
+
+	var quizSessions []models.QuizSession
+	if err := db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Where("user_id = ?", userID).Order("started_at").Find(&quizSessions).Error; err != nil {
+		return err
+	}
+
+	var sessions []models.Session
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&sessions).Error; err != nil {
+		return err
+	}
+
+	var identities []models.UserIdentity
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error; err != nil {
+		return err
+	}
+
+	var securityEvents []models.SecurityEvent
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&securityEvents).Error; err != nil {
+		return err
+	}
+
+	exportedProgressRows := make([]exportedProgress, len(progress))
+	for i := range progress {
+		exportedProgressRows[i] = exportedProgress{UserProgress: progress[i]}
+	}
+	exportedAttempts := make([]exportedAttempt, len(attempts))
+	for i := range attempts {
+		exportedAttempts[i] = exportedAttempt{
+			ExerciseAttempt: attempts[i],
+			Exercise:        exportedExercise{ID: attempts[i].Exercise.ID, Question: attempts[i].Exercise.Question},
+		}
+	}
+	exportedChats := make([]exportedChatSession, len(chatSessions))
+	for i := range chatSessions {
+		messages := make([]exportedChatMessage, len(chatSessions[i].Messages))
+		for j := range chatSessions[i].Messages {
+			messages[j] = exportedChatMessage{ChatMessage: chatSessions[i].Messages[j]}
+		}
+		chatSessions[i].Messages = nil
+		exportedChats[i] = exportedChatSession{ChatSession: chatSessions[i], Messages: messages}
+	}
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", struct {
+			models.User
+			Roles []string `json:"roles"`
+		}{user, roles}},
+		{"progress.json", exportedProgressRows},
+		{"exercise_attempts.json", exportedAttempts},
+		{"chat_sessions.json", exportedChats},
+		{"achievements.json", achievements},
+		{"points.json", pointsEntries},
+		{"streak.json", streak},
+		{"friends.json", friendships},
+		{"classrooms.json", classrooms},
+		{"leagues.json", leagues},
+		{"level_history.json", levelHistory},
+		{"placement_tests.json", placementTests},
+		{"quiz_sessions.json", quizSessions},
+		{"sessions.json", sessions},
+		{"identities.json", identities},
+		{"security_events.json", securityEvents},
+	}
+
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		entry, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
@@ -0,0 +1,134 @@
+// Package account handles self-service export and deletion of user accounts.
+package account
+
+import (
+	"english-learning-app/internal/models"
+	"english-learning-app/internal/throttle"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PurgeDue permanently removes accounts whose deletion grace period ended
+// before now, as well as accounts that were soft-deleted. It returns the
+// number of accounts purged.
+func PurgeDue(db *gorm.DB, now time.Time) (int, error) {
+	return purgeWhere(db, "deletion_due_at <= ? OR deleted_at IS NOT NULL", now)
+}
+
+// PurgeExpiredGuests removes guest accounts that were neither upgraded to a
+// full account nor used within the guest lifetime.
+func PurgeExpiredGuests(db *gorm.DB, now time.Time) (int, error) {
+	return purgeWhere(db, "is_guest = ? AND guest_expires_at <= ?", true, now)
+}
+
+func purgeWhere(db *gorm.DB, query interface{}, args ...interface{}) (int, error) {
+	var users []models.User
+	if err := db.Unscoped().Where(query, args...).Find(&users).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, user := range users {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return Purge(tx, &user)
+		}); err != nil {
+			log.Printf("Failed to purge account %s: %v", user.ID, err)
+			continue
+		}
+		forgetThrottling(&user)
+		purged++
+	}
+
+	return purged, nil
+}
+
+// Purge deletes every row that belongs to user and anonymizes the audit
+// trail. Any new table holding per-user data has to be added here.
+func Purge(tx *gorm.DB, user *models.User) error {
+	// Chat history
+	if err := tx.Where("session_id IN (?)", tx.Model(&models.ChatSession{}).Select("id").Where("user_id = ?", user.ID)).
+		Delete(&models.ChatMessage{}).Error; err != nil {
+		return err
+	}
+
+	// Placement answers
+	if err := tx.Where("test_id IN (?)", tx.Model(&models.PlacementTest{}).Select("id").Where("user_id = ?", user.ID)).
+		Delete(&models.PlacementAnswer{}).Error; err != nil {
+		return err
+	}
+
+	// Quiz questions
+	if err := tx.Where("session_id IN (?)", tx.Model(&models.QuizSession{}).Select("id").Where("user_id = ?", user.ID)).
+		Delete(&models.QuizItem{}).Error; err != nil {
+		return err
+	}
+
+	// Learning data and credentials
+	for _, model := range []interface{}{
+		&models.ChatSession{},
+		&models.ExerciseAttempt{},
+		&models.UserProgress{},
+		&models.RefreshToken{},
+		&models.Session{},
+		&models.UserRole{},
+		&models.UserToken{},
+		&models.TOTPCredential{},
+		&models.MFARecoveryCode{},
+		&models.UserIdentity{},
+		&models.PersonalAccessToken{},
+		&models.PointsEntry{},
+		&models.LeaderboardScore{},
+		&models.UserStreak{},
+		&models.LeagueMembership{},
+		&models.UserLevelHistory{},
+		&models.PlacementTest{},
+		&models.QuizSession{},
+	} {
+		if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Exec("DELETE FROM user_achievements WHERE user_id = ?", user.ID).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("link_user_id = ?", user.ID).Delete(&models.OAuthState{}).Error; err != nil {
+		return err
+	}
+
+	// Social graph, including classrooms the user owns
+	if err := tx.Where("user_id = ? OR friend_id = ?", user.ID, user.ID).Delete(&models.Friendship{}).Error; err != nil {
+		return err
+	}
+	owned := tx.Model(&models.Classroom{}).Select("id").Where("owner_id = ?", user.ID)
+	if err := tx.Where("user_id = ? OR classroom_id IN (?)", user.ID, owned).Delete(&models.ClassroomMember{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("owner_id = ?", user.ID).Delete(&models.Classroom{}).Error; err != nil {
+		return err
+	}
+
+	// Keep security events for statistics but drop everything identifying
+	if err := tx.Model(&models.SecurityEvent{}).
+		Where("user_id = ? OR email = ?", user.ID, user.Email).
+		Updates(map[string]interface{}{"user_id": nil, "email": "", "ip_address": "", "user_agent": ""}).Error; err != nil {
+		return err
+	}
+
+	return tx.Unscoped().Delete(user).Error
+}
+
+// forgetThrottling drops login throttling state keyed by the purged account.
+func forgetThrottling(user *models.User) {
+	if throttle.Accounts == nil {
+		return
+	}
+	for _, key := range []string{strings.ToLower(strings.TrimSpace(user.Email)), "mfa:" + user.ID.String()} {
+		if err := throttle.Accounts.Succeed(key); err != nil {
+			log.Printf("Failed to reset throttling for purged account %s: %v", user.ID, err)
+		}
+	}
+}
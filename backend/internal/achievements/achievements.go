@@ -0,0 +1,229 @@
+// Package achievements grants achievements by evaluating their declarative
+// criteria whenever a learning event happens.
+package achievements
+
+import (
+	"english-learning-app/internal/models"
+	"english-learning-app/internal/points"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Learning events that trigger an evaluation.
+const (
+	EventExerciseAttempted = "exercise_attempted"
+	EventTopicCompleted    = "topic_completed"
+	EventStreakUpdated     = "streak_updated"
+)
+
+// Metrics that criteria can be declared on.
+const (
+	// Topics completed by learning them, not by testing out
+	MetricTopicsCompleted = "topics_completed"
+	// Levels whose active topics are all completed that way
+	MetricLevelsCompleted = "levels_completed"
+	// Completed topics scored at full marks
+	MetricPerfectTopics = "perfect_topics"
+	// Distinct exercises answered correctly at least once
+	MetricCorrectAnswers = "correct_answers"
+	// Exercise attempts, right or wrong
+	MetricAttempts = "attempts"
+	// Current points balance
+	MetricPoints = "points"
+	// Consecutive days with the daily goal met
+	MetricStreakDays = "streak_days"
+)
+
+var (
+	events  = []string{EventExerciseAttempted, EventTopicCompleted, EventStreakUpdated}
+	metrics = []string{MetricTopicsCompleted, MetricLevelsCompleted, MetricPerfectTopics, MetricCorrectAnswers, MetricAttempts, MetricPoints, MetricStreakDays}
+)
+
+var ErrInvalidCriteria = errors.New("invalid achievement criteria")
+
+// Validate reports whether criteria can be evaluated. Nil criteria are valid
+// and mean the achievement is only ever granted manually.
+func Validate(criteria *models.AchievementCriteria) error {
+	if criteria == nil {
+		return nil
+	}
+	if criteria.Event != "" && !contains(events, criteria.Event) {
+		return fmt.Errorf("%w: unknown event %q, expected one of %s", ErrInvalidCriteria, criteria.Event, strings.Join(events, ", "))
+	}
+	if !contains(metrics, criteria.Metric) {
+		return fmt.Errorf("%w: unknown metric %q, expected one of %s", ErrInvalidCriteria, criteria.Metric, strings.Join(metrics, ", "))
+	}
+	if criteria.Threshold <= 0 {
+		return fmt.Errorf("%w: threshold must be positive", ErrInvalidCriteria)
+	}
+	if criteria.ExerciseType != "" && criteria.Metric != MetricCorrectAnswers && criteria.Metric != MetricAttempts {
+		return fmt.Errorf("%w: exercise_type only applies to %s and %s", ErrInvalidCriteria, MetricCorrectAnswers, MetricAttempts)
+	}
+	if criteria.Level != "" && (criteria.Metric == MetricPoints || criteria.Metric == MetricStreakDays) {
+		return fmt.Errorf("%w: level does not apply to %s", ErrInvalidCriteria, criteria.Metric)
+	}
+	return nil
+}
+
+// Evaluate grants every active achievement the user has not earned yet whose
+// criteria listen for event and are now met. It returns the newly granted
+// achievements, never nil.
+func Evaluate(db *gorm.DB, userID uuid.UUID, event string) ([]models.Achievement, error) {
+	granted := []models.Achievement{}
+
+	var candidates []models.Achievement
+	if err := db.Where("is_active = ? AND criteria IS NOT NULL AND criteria <> 'null'", true).
+		Where("NOT EXISTS (?)", db.Model(&models.UserAchievement{}).Select("1").
+			Where("user_achievements.achievement_id = achievements.id AND user_achievements.user_id = ?", userID)).
+		Find(&candidates).Error; err != nil {
+		return granted, err
+	}
+
+	values := map[models.AchievementCriteria]int{}
+	for _, achievement := range candidates {
+		criteria := achievement.Criteria
+		if criteria == nil || (criteria.Event != "" && criteria.Event != event) {
+			continue
+		}
+
+		// Several achievements usually share a metric with different
+		// thresholds, so each query runs once per evaluation
+		key := *criteria
+		key.Event, key.Threshold = "", 0
+		value, ok := values[key]
+		if !ok {
+			var err error
+			value, err = measure(db, userID, criteria)
+			if err != nil {
+				return granted, err
+			}
+			values[key] = value
+		}
+		if value < criteria.Threshold {
+			continue
+		}
+
+		ok, err := Grant(db, userID, &achievement)
+		if err != nil {
+			return granted, err
+		}
+		if !ok {
+			continue
+		}
+		granted = append(granted, achievement)
+
+		// The achievement's own points may unlock points based achievements
+		if achievement.Points != 0 {
+			for key := range values {
+				if key.Metric == MetricPoints {
+					delete(values, key)
+				}
+			}
+		}
+	}
+
+	return granted, nil
+}
+
+// Grant gives the achievement to the user and awards its points. Granting an
+// achievement the user already has does nothing and reports false.
+func Grant(db *gorm.DB, userID uuid.UUID, achievement *models.Achievement) (bool, error) {
+	granted := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.UserAchievement{
+			UserID:        userID,
+			AchievementID: achievement.ID,
+			GrantedAt:     time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		granted = true
+
+		if achievement.Points == 0 {
+			return nil
+		}
+		_, err := points.Award(tx, &models.PointsEntry{
+			UserID:         userID,
+			SourceType:     models.PointsSourceAchievement,
+			SourceID:       &achievement.ID,
+			Delta:          achievement.Points,
+			IdempotencyKey: fmt.Sprintf("achievement:%s:%s", userID, achievement.ID),
+		})
+		return err
+	})
+	return granted, err
+}
+
+// measure computes the current value of the criteria's metric for the user.
+func measure(db *gorm.DB, userID uuid.UUID, criteria *models.AchievementCriteria) (int, error) {
+	var query string
+	args := []interface{}{userID}
+
+	switch criteria.Metric {
+	case MetricTopicsCompleted, MetricPerfectTopics:
+		query = `SELECT COUNT(*) FROM user_progresses up
+			JOIN topics t ON t.id = up.topic_id
+			JOIN levels l ON l.id = t.level_id
+			WHERE up.user_id = ? AND up.completed AND NOT up.tested_out`
+		if criteria.Metric == MetricPerfectTopics {
+			query += ` AND EXISTS (SELECT 1 FROM exercises e WHERE e.topic_id = t.id AND e.is_active)
+				AND up.score >= (SELECT SUM(e.points) FROM exercises e WHERE e.topic_id = t.id AND e.is_active)`
+		}
+	case MetricLevelsCompleted:
+		query = `SELECT COUNT(*) FROM levels l
+			WHERE NOT EXISTS (
+				SELECT 1 FROM topics t WHERE t.level_id = l.id AND t.is_active AND NOT EXISTS (
+					SELECT 1 FROM user_progresses up WHERE up.topic_id = t.id AND up.user_id = ? AND up.completed AND NOT up.tested_out))
+			AND EXISTS (SELECT 1 FROM topics t WHERE t.level_id = l.id AND t.is_active)`
+	case MetricCorrectAnswers, MetricAttempts:
+		query = `SELECT COUNT(*) FROM exercise_attempts a`
+		if criteria.Metric == MetricCorrectAnswers {
+			query = `SELECT COUNT(DISTINCT a.exercise_id) FROM exercise_attempts a`
+		}
+		query += ` JOIN exercises e ON e.id = a.exercise_id
+			JOIN topics t ON t.id = e.topic_id
+			JOIN levels l ON l.id = t.level_id
+			WHERE a.user_id = ?`
+		if criteria.Metric == MetricCorrectAnswers {
+			query += ` AND a.is_correct`
+		}
+		if criteria.ExerciseType != "" {
+			query += ` AND e.type = ?`
+			args = append(args, criteria.ExerciseType)
+		}
+	case MetricPoints:
+		query = `SELECT points FROM users WHERE id = ?`
+	case MetricStreakDays:
+		query = `SELECT COALESCE(MAX(current_streak), 0) FROM user_streaks WHERE user_id = ?`
+	default:
+		return 0, fmt.Errorf("%w: unknown metric %q", ErrInvalidCriteria, criteria.Metric)
+	}
+
+	if criteria.Level != "" {
+		query += ` AND l.name = ?`
+		args = append(args, criteria.Level)
+	}
+
+	var value int
+	err := db.Raw(query, args...).Scan(&value).Error
+	return value, err
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -3,13 +3,28 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
-	OpenAI   OpenAIConfig
+	Server       ServerConfig
+	Database     DatabaseConfig
+	JWT          JWTConfig
+	OpenAI       OpenAIConfig
+	App          AppConfig
+	Mail         MailConfig
+	Verification VerificationConfig
+	Password     PasswordConfig
+	Throttle     ThrottleConfig
+	Progress     ProgressConfig
+	Grading      GradingConfig
+	Placement    PlacementConfig
+	Quiz         QuizConfig
+	Streak       StreakConfig
+	Leaderboard  LeaderboardConfig
+	League       LeagueConfig
+	Account      AccountConfig
+	OIDC         OIDCConfig
 }
 
 type ServerConfig struct {
@@ -27,15 +42,117 @@ type DatabaseConfig struct {
 }
 
 type JWTConfig struct {
-	SecretKey          string
-	AccessTokenExpiry  int // minutes
-	RefreshTokenExpiry int // hours
+	Algorithm          string // RS256, EdDSA or legacy HS256
+	SecretKey          string // HS256 only
+	KeysDir            string // directory of <kid>.pem files for RS256/EdDSA
+	ActiveKeyID        string // key used for signing, defaults to the newest
+	AccessTokenExpiry  int    // minutes
+	RefreshTokenExpiry int    // hours
+	MFATokenExpiry     int    // minutes
 }
 
 type OpenAIConfig struct {
 	APIKey string
 }
 
+type AppConfig struct {
+	Env         string // development, production
+	Name        string // shown in authenticator apps
+	FrontendURL string // used to build links in emails
+}
+
+type MailConfig struct {
+	Driver       string // smtp, file, memory
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	FileDir      string
+}
+
+type VerificationConfig struct {
+	TokenExpiry int // hours
+	// Features unavailable until the email address is verified,
+	// e.g. "leaderboard", "ai_chat"
+	RestrictedFeatures []string
+}
+
+type PasswordConfig struct {
+	ResetTokenExpiry int // minutes
+}
+
+type ThrottleConfig struct {
+	Store              string // memory, postgres
+	MaxAccountFailures int    // failed logins per account before lockout
+	MaxIPFailures      int    // failed logins per client IP before lockout
+	LockoutMinutes     int
+}
+
+type ProgressConfig struct {
+	PassThreshold int // percent of a topic's points needed to complete it
+}
+
+type GradingConfig struct {
+	IgnoreCase         bool
+	IgnorePunctuation  bool
+	CollapseWhitespace bool
+	NormalizeQuotes    bool // curly quotes and apostrophes count as straight ones
+	ExpandContractions bool // "don't" matches "do not"
+	MaxTypoDistance    int  // most edits accepted as a typo, 0 to disable
+	TypoCreditPercent  int  // share of the points awarded for an answer with typos
+}
+
+type PlacementConfig struct {
+	MaxQuestions  int // questions after which a placement test always ends
+	StopReversals int // direction changes after which the estimate is trusted
+}
+
+type QuizConfig struct {
+	MaxTimeLimitSeconds int // longest time limit a quiz can be started with
+	GraceSeconds        int // extra time for answers sent just before the limit
+}
+
+type StreakConfig struct {
+	FreezeCost int // points charged for one streak freeze
+	MaxFreezes int // freezes a user can hold at once
+}
+
+type LeaderboardConfig struct {
+	SyncIntervalMinutes int // how often stored scores are checked against the ledger
+}
+
+type LeagueConfig struct {
+	CohortSize           int // learners per weekly league
+	PromoteCount         int // top finishers moving up a tier
+	RelegateCount        int // bottom finishers moving down a tier
+	CloseIntervalMinutes int // how often the week closing job runs
+}
+
+type AccountConfig struct {
+	DeletionGraceDays    int // days before a deleted account is purged
+	PurgeIntervalMinutes int // how often the purge job runs
+	ReauthMaxAgeMinutes  int // a login this recent stands in for the password
+	GuestLifetimeHours   int // guest accounts inactive for this long are purged
+	MaxGuestsPerIP       int // guest accounts one client IP may create per hour
+}
+
+type OIDCConfig struct {
+	Providers []OIDCProviderConfig
+}
+
+// OIDCProviderConfig describes one OpenID Connect identity provider. Providers
+// are listed in OIDC_PROVIDERS and configured with OIDC_<NAME>_* variables.
+type OIDCProviderConfig struct {
+	Name         string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // frontend page that receives the authorization code
+	Scopes       []string
+}
+
 func LoadConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
@@ -51,13 +168,87 @@ func LoadConfig() *Config {
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
 		},
 		JWT: JWTConfig{
+			Algorithm:          getEnv("JWT_ALGORITHM", "RS256"),
 			SecretKey:          getEnv("JWT_SECRET", "your-secret-key"),
+			KeysDir:            getEnv("JWT_KEYS_DIR", ""),
+			ActiveKeyID:        getEnv("JWT_ACTIVE_KEY_ID", ""),
 			AccessTokenExpiry:  getEnvAsInt("JWT_ACCESS_EXPIRY", 60),  // 60 minutes
 			RefreshTokenExpiry: getEnvAsInt("JWT_REFRESH_EXPIRY", 24), // 24 hours
+			MFATokenExpiry:     getEnvAsInt("JWT_MFA_EXPIRY", 5),      // 5 minutes
 		},
 		OpenAI: OpenAIConfig{
 			APIKey: getEnv("OPENAI_API_KEY", ""),
 		},
+		App: AppConfig{
+			Env:         getEnv("APP_ENV", "production"), // development must be opted into
+			Name:        getEnv("APP_NAME", "EnUP"),
+			FrontendURL: getEnv("FRONTEND_URL", "http://localhost:5173"),
+		},
+		Mail: MailConfig{
+			Driver:       getEnv("MAIL_DRIVER", "file"),
+			From:         getEnv("MAIL_FROM", "noreply@localhost"),
+			SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+			SMTPPort:     getEnv("SMTP_PORT", "587"),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			FileDir:      getEnv("MAIL_FILE_DIR", "./mail"),
+		},
+		Verification: VerificationConfig{
+			TokenExpiry:        getEnvAsInt("EMAIL_VERIFICATION_EXPIRY", 48), // 48 hours
+			RestrictedFeatures: getEnvAsList("UNVERIFIED_RESTRICTED_FEATURES", []string{"leaderboard", "ai_chat"}),
+		},
+		Password: PasswordConfig{
+			ResetTokenExpiry: getEnvAsInt("PASSWORD_RESET_EXPIRY", 30), // 30 minutes
+		},
+		Throttle: ThrottleConfig{
+			Store:              getEnv("LOGIN_THROTTLE_STORE", "postgres"),
+			MaxAccountFailures: getEnvAsInt("LOGIN_MAX_ACCOUNT_FAILURES", 10),
+			MaxIPFailures:      getEnvAsInt("LOGIN_MAX_IP_FAILURES", 100),
+			LockoutMinutes:     getEnvAsInt("LOGIN_LOCKOUT_MINUTES", 15),
+		},
+		Progress: ProgressConfig{
+			PassThreshold: getEnvAsInt("TOPIC_PASS_THRESHOLD", 70),
+		},
+		Grading: GradingConfig{
+			IgnoreCase:         getEnvAsBool("GRADING_IGNORE_CASE", true),
+			IgnorePunctuation:  getEnvAsBool("GRADING_IGNORE_PUNCTUATION", true),
+			CollapseWhitespace: getEnvAsBool("GRADING_COLLAPSE_WHITESPACE", true),
+			NormalizeQuotes:    getEnvAsBool("GRADING_NORMALIZE_QUOTES", true),
+			ExpandContractions: getEnvAsBool("GRADING_EXPAND_CONTRACTIONS", true),
+			MaxTypoDistance:    getEnvAsInt("GRADING_MAX_TYPO_DISTANCE", 2),
+			TypoCreditPercent:  getEnvAsInt("GRADING_TYPO_CREDIT_PERCENT", 50),
+		},
+		Placement: PlacementConfig{
+			MaxQuestions:  getEnvAsInt("PLACEMENT_MAX_QUESTIONS", 20),
+			StopReversals: getEnvAsInt("PLACEMENT_STOP_REVERSALS", 6),
+		},
+		Quiz: QuizConfig{
+			MaxTimeLimitSeconds: getEnvAsInt("QUIZ_MAX_TIME_LIMIT", 3600),
+			GraceSeconds:        getEnvAsInt("QUIZ_TIME_GRACE", 5),
+		},
+		Streak: StreakConfig{
+			FreezeCost: getEnvAsInt("STREAK_FREEZE_COST", 50),
+			MaxFreezes: getEnvAsInt("STREAK_MAX_FREEZES", 2),
+		},
+		Leaderboard: LeaderboardConfig{
+			SyncIntervalMinutes: getEnvAsInt("LEADERBOARD_SYNC_INTERVAL", 30),
+		},
+		League: LeagueConfig{
+			CohortSize:           getEnvAsInt("LEAGUE_COHORT_SIZE", 30),
+			PromoteCount:         getEnvAsInt("LEAGUE_PROMOTE_COUNT", 7),
+			RelegateCount:        getEnvAsInt("LEAGUE_RELEGATE_COUNT", 5),
+			CloseIntervalMinutes: getEnvAsInt("LEAGUE_CLOSE_INTERVAL", 60),
+		},
+		Account: AccountConfig{
+			DeletionGraceDays:    getEnvAsInt("ACCOUNT_DELETION_GRACE_DAYS", 30),
+			PurgeIntervalMinutes: getEnvAsInt("ACCOUNT_PURGE_INTERVAL", 60),
+			ReauthMaxAgeMinutes:  getEnvAsInt("ACCOUNT_REAUTH_MAX_AGE", 10),
+			GuestLifetimeHours:   getEnvAsInt("GUEST_LIFETIME_HOURS", 72),
+			MaxGuestsPerIP:       getEnvAsInt("GUEST_MAX_PER_IP", 20),
+		},
+		OIDC: OIDCConfig{
+			Providers: loadOIDCProviders(),
+		},
 	}
 }
 
@@ -76,3 +267,49 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsList reads a comma separated list. Setting the variable to "none"
+// yields an empty list.
+func getEnvAsList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	if value == "none" {
+		return []string{}
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func loadOIDCProviders() []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range getEnvAsList("OIDC_PROVIDERS", nil) {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers = append(providers, OIDCProviderConfig{
+			Name:         name,
+			DisplayName:  getEnv(prefix+"DISPLAY_NAME", name),
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", "http://localhost:5173/auth/callback/"+name),
+			Scopes:       getEnvAsList(prefix+"SCOPES", []string{"openid", "email", "profile"}),
+		})
+	}
+	return providers
+}
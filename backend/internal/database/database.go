@@ -1,6 +1,7 @@
 package database
 
 import (
+	"english-learning-app/internal/achievements"
 	"english-learning-app/internal/config"
 	"english-learning-app/internal/models"
 	"fmt"
@@ -36,6 +37,15 @@ func ConnectDB(cfg *config.Config) error {
 }
 
 func AutoMigrate() error {
+	// Must run before the users table is migrated
+	if err := DB.SetupJoinTable(&models.User{}, "Achievements", &models.UserAchievement{}); err != nil {
+		return fmt.Errorf("failed to setup achievements join table: %w", err)
+	}
+
+	// Accounts created before email verification existed never went through
+	// it, so they are marked verified once, when the column is added
+	backfillVerified := DB.Migrator().HasTable(&models.User{}) && !DB.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt")
+
 	err := DB.AutoMigrate(
 		&models.User{},
 		&models.UserProgress{},
@@ -46,11 +56,43 @@ func AutoMigrate() error {
 		&models.ExerciseAttempt{},
 		&models.ChatSession{},
 		&models.ChatMessage{},
+		&models.RefreshToken{},
+		&models.Session{},
+		&models.UserRole{},
+		&models.UserToken{},
+		&models.SecurityEvent{},
+		&models.LoginThrottle{},
+		&models.TOTPCredential{},
+		&models.MFARecoveryCode{},
+		&models.UserIdentity{},
+		&models.OAuthState{},
+		&models.PersonalAccessToken{},
+		&models.PointsEntry{},
+		&models.LeaderboardScore{},
+		&models.UserStreak{},
+		&models.Friendship{},
+		&models.Classroom{},
+		&models.ClassroomMember{},
+		&models.League{},
+		&models.LeagueMembership{},
+		&models.UserLevelHistory{},
+		&models.PlacementTest{},
+		&models.PlacementAnswer{},
+		&models.QuizSession{},
+		&models.QuizItem{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	if backfillVerified {
+		result := DB.Model(&models.User{}).Where("email_verified_at IS NULL").Update("email_verified_at", gorm.Expr("created_at"))
+		if result.Error != nil {
+			return fmt.Errorf("failed to backfill email verification: %w", result.Error)
+		}
+		log.Printf("Marked %d existing users as verified", result.RowsAffected)
+	}
+
 	log.Println("Database migrated successfully")
 	return nil
 }
@@ -761,17 +803,24 @@ func SeedData() error {
 		}
 	}
 
-	// Seed achievements
-	achievements := []models.Achievement{
-		{Name: "First Step", Description: "Complete your first lesson", Icon: "🎯", Points: 10},
-		{Name: "Dedicated Learner", Description: "Complete 10 lessons", Icon: "📚", Points: 50},
-		{Name: "Level Master", Description: "Complete a full level", Icon: "🏆", Points: 100},
-		{Name: "Perfect Score", Description: "Get 100% on an exercise", Icon: "⭐", Points: 25},
-		{Name: "Grammar Guru", Description: "Complete all grammar exercises", Icon: "📝", Points: 75},
-		{Name: "Vocabulary Master", Description: "Learn 100 new words", Icon: "📖", Points: 150},
+	// Seed achievements. Grammar Guru and Vocabulary Master have no criteria
+	// yet because exercises are not tagged by skill; admins can add them.
+	seedAchievements := []models.Achievement{
+		{Code: stringPtr("first_step"), Name: "First Step", Description: "Complete your first lesson", Icon: "🎯", Points: 10,
+			Criteria: &models.AchievementCriteria{Event: achievements.EventTopicCompleted, Metric: achievements.MetricTopicsCompleted, Threshold: 1}},
+		{Code: stringPtr("dedicated_learner"), Name: "Dedicated Learner", Description: "Complete 10 lessons", Icon: "📚", Points: 50,
+			Criteria: &models.AchievementCriteria{Event: achievements.EventTopicCompleted, Metric: achievements.MetricTopicsCompleted, Threshold: 10}},
+		{Code: stringPtr("level_master"), Name: "Level Master", Description: "Complete a full level", Icon: "🏆", Points: 100,
+			Criteria: &models.AchievementCriteria{Event: achievements.EventTopicCompleted, Metric: achievements.MetricLevelsCompleted, Threshold: 1}},
+		{Code: stringPtr("perfect_score"), Name: "Perfect Score", Description: "Complete a lesson with 100%", Icon: "⭐", Points: 25,
+			Criteria: &models.AchievementCriteria{Event: achievements.EventTopicCompleted, Metric: achievements.MetricPerfectTopics, Threshold: 1}},
+		{Code: stringPtr("week_streak"), Name: "On Fire", Description: "Meet your daily goal 7 days in a row", Icon: "🔥", Points: 30,
+			Criteria: &models.AchievementCriteria{Event: achievements.EventStreakUpdated, Metric: achievements.MetricStreakDays, Threshold: 7}},
+		{Code: stringPtr("grammar_guru"), Name: "Grammar Guru", Description: "Complete all grammar exercises", Icon: "📝", Points: 75},
+		{Code: stringPtr("vocabulary_master"), Name: "Vocabulary Master", Description: "Learn 100 new words", Icon: "📖", Points: 150},
 	}
 
-	for _, achievement := range achievements {
+	for _, achievement := range seedAchievements {
 		var existingAchievement models.Achievement
 		if err := DB.Where("name = ?", achievement.Name).First(&existingAchievement).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
@@ -779,9 +828,25 @@ func SeedData() error {
 					return fmt.Errorf("failed to seed achievement %s: %w", achievement.Name, err)
 				}
 			}
+		} else if existingAchievement.Code == nil {
+			// Seeded before achievements had codes and criteria
+			if err := DB.Model(&existingAchievement).Select("code", "criteria").Updates(&achievement).Error; err != nil {
+				return fmt.Errorf("failed to update achievement %s: %w", achievement.Name, err)
+			}
 		}
 	}
 
+	// Perfect Score was described as a single exercise, but counts topics
+	if err := DB.Model(&models.Achievement{}).
+		Where("code = ? AND description = ?", "perfect_score", "Get 100% on an exercise").
+		Update("description", "Complete a lesson with 100%").Error; err != nil {
+		return fmt.Errorf("failed to update achievement Perfect Score: %w", err)
+	}
+
 	log.Println("Database seeded successfully")
 	return nil
 }
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,379 @@
+package grading
+
+import (
+	"bytes"
+	"encoding/json"
+	"english-learning-app/internal/models"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// VerdictPartial is given to structured answers that are only partly right.
+const VerdictPartial = "partial"
+
+var ErrInvalidAnswer = errors.New("answer does not match the exercise type")
+
+// blankMarker marks the gaps of a cloze passage: {1}, {2}, ...
+var blankMarker = regexp.MustCompile(`\{(\d+)\}`)
+
+// Validate checks the type-specific fields of an exercise before it is saved.
+// For the structured types it derives CorrectAnswer from the payload, so
+// exports and reports stay readable, and drops payload fields of other types.
+func Validate(exercise *models.Exercise) error {
+	exercise.CorrectAnswer = strings.TrimSpace(exercise.CorrectAnswer)
+	payload := exercise.Payload
+	exercise.Payload = nil
+
+	switch exercise.Type {
+	case models.ExerciseFillBlank, models.ExerciseTranslation, models.ExerciseAudio:
+		if exercise.CorrectAnswer == "" {
+			return errors.New("correct_answer is required")
+		}
+
+	case models.ExerciseMultipleChoice:
+		if len(exercise.Options) < 2 {
+			return errors.New("multiple choice exercises need at least two options")
+		}
+		if !contains(exercise.Options, exercise.CorrectAnswer) {
+			return errors.New("correct_answer must be one of the options")
+		}
+
+	case models.ExerciseTrueFalse:
+		value, err := strconv.ParseBool(exercise.CorrectAnswer)
+		if err != nil {
+			return errors.New(`correct_answer must be "true" or "false"`)
+		}
+		exercise.CorrectAnswer = strconv.FormatBool(value)
+
+	case models.ExerciseMatching:
+		if payload == nil || len(payload.Pairs) < 2 {
+			return errors.New("matching exercises need at least two pairs in payload.pairs")
+		}
+		lefts := make(map[string]bool, len(payload.Pairs))
+		rights := make(map[string]bool, len(payload.Pairs))
+		described := make([]string, len(payload.Pairs))
+		for i, pair := range payload.Pairs {
+			pair.Left, pair.Right = strings.TrimSpace(pair.Left), strings.TrimSpace(pair.Right)
+			if pair.Left == "" || pair.Right == "" {
+				return errors.New("matching pairs need both sides")
+			}
+			if lefts[pair.Left] || rights[pair.Right] {
+				return fmt.Errorf("%q = %q repeats a word of another pair", pair.Left, pair.Right)
+			}
+			lefts[pair.Left], rights[pair.Right] = true, true
+			payload.Pairs[i] = pair
+			described[i] = pair.Left + " = " + pair.Right
+		}
+		exercise.Payload = &models.ExercisePayload{Pairs: payload.Pairs}
+		exercise.CorrectAnswer = strings.Join(described, "; ")
+
+	case models.ExerciseWordOrder:
+		if payload == nil || len(payload.Words) < 2 {
+			return errors.New("word ordering exercises need at least two words in payload.words")
+		}
+		for i, word := range payload.Words {
+			if payload.Words[i] = strings.TrimSpace(word); payload.Words[i] == "" || strings.ContainsAny(payload.Words[i], " \t\n") {
+				return errors.New("payload.words must be single, non-empty words")
+			}
+		}
+		// Accepted answers may list other valid orders of the same words
+		exercise.Payload = &models.ExercisePayload{Words: payload.Words}
+		exercise.CorrectAnswer = strings.Join(payload.Words, " ")
+
+	case models.ExerciseMultiSelect:
+		if len(exercise.Options) < 2 {
+			return errors.New("multi-select exercises need at least two options")
+		}
+		if payload == nil || len(payload.Correct) == 0 {
+			return errors.New("multi-select exercises need the options to select in payload.correct")
+		}
+		seen := make(map[string]bool, len(payload.Correct))
+		for _, option := range payload.Correct {
+			if !contains(exercise.Options, option) {
+				return fmt.Errorf("%q in payload.correct is not one of the options", option)
+			}
+			if seen[option] {
+				return fmt.Errorf("%q is listed twice in payload.correct", option)
+			}
+			seen[option] = true
+		}
+		exercise.Payload = &models.ExercisePayload{Correct: payload.Correct}
+		exercise.CorrectAnswer = strings.Join(payload.Correct, "; ")
+
+	case models.ExerciseCloze:
+		if payload == nil || len(payload.Blanks) == 0 {
+			return errors.New("cloze exercises need their blanks in payload.blanks")
+		}
+		if len(exercise.AcceptedAnswers) > 0 {
+			return errors.New("cloze exercises take accepted answers per blank")
+		}
+		if err := validateMarkers(exercise.Question, len(payload.Blanks)); err != nil {
+			return err
+		}
+		described := make([]string, len(payload.Blanks))
+		for i, blank := range payload.Blanks {
+			n := i + 1
+			var answers []string
+			for _, answer := range blank.Answers {
+				if answer = strings.TrimSpace(answer); answer != "" {
+					answers = append(answers, answer)
+				}
+			}
+			if len(answers) == 0 {
+				return fmt.Errorf("blank {%d} needs at least one answer", n)
+			}
+			if len(blank.Options) == 1 {
+				return fmt.Errorf("the word bank of blank {%d} needs at least two options", n)
+			}
+			if len(blank.Options) > 0 && !contains(blank.Options, answers[0]) {
+				return fmt.Errorf("the word bank of blank {%d} must contain %q", n, answers[0])
+			}
+			payload.Blanks[i].Answers = answers
+			described[i] = fmt.Sprintf("{%d} %s", n, answers[0])
+		}
+		exercise.Payload = &models.ExercisePayload{Blanks: payload.Blanks}
+		exercise.CorrectAnswer = strings.Join(described, "; ")
+
+	default:
+		return fmt.Errorf("unknown exercise type %q", exercise.Type)
+	}
+	return nil
+}
+
+// validateMarkers checks that question marks each of count blanks exactly once.
+func validateMarkers(question string, count int) error {
+	seen := make(map[int]bool, count)
+	for _, match := range blankMarker.FindAllStringSubmatch(question, -1) {
+		n, _ := strconv.Atoi(match[1])
+		if n < 1 || n > count {
+			return fmt.Errorf("the question marks blank {%d}, but payload.blanks has %d", n, count)
+		}
+		if seen[n] {
+			return fmt.Errorf("the question marks blank {%d} twice", n)
+		}
+		seen[n] = true
+	}
+	for n := 1; n <= count; n++ {
+		if !seen[n] {
+			return fmt.Errorf("the question has no marker for blank {%d}", n)
+		}
+	}
+	return nil
+}
+
+func contains(options []string, value string) bool {
+	for _, option := range options {
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
+
+// PromptFor returns what learners see of a structured exercise's payload:
+// the sides of a matching exercise, the words of a sentence and the word
+// banks of a cloze passage, shuffled.
+// Other types need nothing beyond the question and options.
+func PromptFor(exercise *models.Exercise) *models.ExercisePrompt {
+	if exercise.Payload == nil {
+		return nil
+	}
+	switch exercise.Type {
+	case models.ExerciseMatching:
+		prompt := &models.ExercisePrompt{}
+		for _, pair := range exercise.Payload.Pairs {
+			prompt.Left = append(prompt.Left, pair.Left)
+			prompt.Right = append(prompt.Right, pair.Right)
+		}
+		shuffle(prompt.Left)
+		shuffle(prompt.Right)
+		return prompt
+	case models.ExerciseWordOrder:
+		words := append([]string(nil), exercise.Payload.Words...)
+		shuffle(words)
+		return &models.ExercisePrompt{Words: words}
+	case models.ExerciseCloze:
+		prompt := &models.ExercisePrompt{Blanks: make([][]string, len(exercise.Payload.Blanks))}
+		for i, blank := range exercise.Payload.Blanks {
+			prompt.Blanks[i] = append([]string{}, blank.Options...)
+			shuffle(prompt.Blanks[i])
+		}
+		return prompt
+	}
+	return nil
+}
+
+func shuffle(items []string) {
+	rand.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+}
+
+// GradeExercise grades an answer in the format of the exercise's type:
+//
+//	free-text types and multiple choice   "answer"
+//	true_false                            true
+//	matching                              {"left word": "right word", ...}
+//	word_order                            ["words", "in", "order"]
+//	multi_select                          ["selected option", ...]
+//	cloze                                 ["blank 1", "blank 2", ...]
+//
+// It also returns the answer as stored on the attempt. An answer in the
+// wrong format yields ErrInvalidAnswer.
+func GradeExercise(exercise *models.Exercise, answer json.RawMessage, opts Options) (Result, string, error) {
+	switch exercise.Type {
+	case models.ExerciseTrueFalse:
+		var value bool
+		if err := json.Unmarshal(answer, &value); err != nil {
+			return Result{}, "", ErrInvalidAnswer
+		}
+		given := strconv.FormatBool(value)
+		if given == exercise.CorrectAnswer {
+			return Result{Verdict: VerdictCorrect, IsCorrect: true, Score: exercise.Points}, given, nil
+		}
+		return Result{Verdict: VerdictIncorrect}, given, nil
+
+	case models.ExerciseMatching:
+		var matches map[string]string
+		if err := json.Unmarshal(answer, &matches); err != nil || exercise.Payload == nil {
+			return Result{}, "", ErrInvalidAnswer
+		}
+		given := make(map[string]string, len(matches))
+		for left, right := range matches {
+			given[Normalize(left, opts)] = Normalize(right, opts)
+		}
+		right := 0
+		for _, pair := range exercise.Payload.Pairs {
+			if match, ok := given[Normalize(pair.Left, opts)]; ok && match == Normalize(pair.Right, opts) {
+				right++
+			}
+		}
+		return partial(right, len(exercise.Payload.Pairs), exercise.Points), compact(answer), nil
+
+	case models.ExerciseWordOrder:
+		var words []string
+		if err := json.Unmarshal(answer, &words); err != nil {
+			return Result{}, "", ErrInvalidAnswer
+		}
+		// The words are given, so a misplaced one is never a typo
+		sentence := strings.Join(words, " ")
+		accepted := append([]string{exercise.CorrectAnswer}, exercise.AcceptedAnswers...)
+		return Grade(sentence, accepted, exercise.Points, false, opts), sentence, nil
+
+	case models.ExerciseMultiSelect:
+		var selected []string
+		if err := json.Unmarshal(answer, &selected); err != nil || exercise.Payload == nil {
+			return Result{}, "", ErrInvalidAnswer
+		}
+		correct := make(map[string]bool, len(exercise.Payload.Correct))
+		for _, option := range exercise.Payload.Correct {
+			correct[strings.TrimSpace(option)] = true
+		}
+		// Each wrong pick cancels a right one, so selecting everything
+		// doesn't earn anything
+		right, seen := 0, make(map[string]bool, len(selected))
+		for _, option := range selected {
+			option = strings.TrimSpace(option)
+			if seen[option] {
+				continue
+			}
+			seen[option] = true
+			if correct[option] {
+				right++
+			} else {
+				right--
+			}
+		}
+		return partial(max(right, 0), len(correct), exercise.Points), compact(answer), nil
+
+	case models.ExerciseCloze:
+		var filled []string
+		if err := json.Unmarshal(answer, &filled); err != nil || exercise.Payload == nil || len(filled) != len(exercise.Payload.Blanks) {
+			return Result{}, "", ErrInvalidAnswer
+		}
+		return gradeCloze(exercise, filled, opts), compact(answer), nil
+	}
+
+	var text string
+	if err := json.Unmarshal(answer, &text); err != nil {
+		return Result{}, "", ErrInvalidAnswer
+	}
+	accepted := append([]string{exercise.CorrectAnswer}, exercise.AcceptedAnswers...)
+	if exercise.Type == models.ExerciseMultipleChoice {
+		return gradeChoice(text, accepted, exercise.Points), text, nil
+	}
+	return Grade(text, accepted, exercise.Points, true, opts), text, nil
+}
+
+// gradeChoice grades an option picked from the ones shown. Options are
+// matched as written, apart from surrounding space, as normalizing them
+// could make two distinct options such as "its" and "it's" equal.
+func gradeChoice(answer string, accepted []string, points int) Result {
+	given := strings.TrimSpace(answer)
+	for _, candidate := range accepted {
+		if given != "" && given == strings.TrimSpace(candidate) {
+			return Result{Verdict: VerdictCorrect, IsCorrect: true, Score: points}
+		}
+	}
+	return Result{Verdict: VerdictIncorrect}
+}
+
+// gradeCloze grades each blank on its own, worth an equal share of the
+// exercise's points. Blanks with a word bank are graded as a choice.
+func gradeCloze(exercise *models.Exercise, filled []string, opts Options) Result {
+	blanks := exercise.Payload.Blanks
+	result := Result{Verdict: VerdictCorrect, IsCorrect: true, Blanks: make([]BlankResult, len(blanks))}
+	earned := 0
+	for i, blank := range blanks {
+		var graded Result
+		if len(blank.Options) > 0 {
+			graded = gradeChoice(filled[i], blank.Answers, exercise.Points)
+		} else {
+			graded = Grade(filled[i], blank.Answers, exercise.Points, true, opts)
+		}
+		result.Blanks[i] = BlankResult{
+			Blank:     i + 1,
+			Verdict:   graded.Verdict,
+			IsCorrect: graded.IsCorrect,
+			Corrected: graded.Corrected,
+		}
+		earned += graded.Score
+
+		switch {
+		case !graded.IsCorrect:
+			result.IsCorrect = false
+		case graded.Verdict == VerdictTypo && result.Verdict == VerdictCorrect:
+			result.Verdict = VerdictTypo
+		}
+	}
+
+	result.Score = earned / len(blanks)
+	if !result.IsCorrect {
+		result.Verdict = VerdictIncorrect
+		if result.Score > 0 {
+			result.Verdict = VerdictPartial
+		}
+	}
+	return result
+}
+
+// partial awards points in proportion to the right parts of an answer.
+func partial(right, total, points int) Result {
+	switch {
+	case total > 0 && right == total:
+		return Result{Verdict: VerdictCorrect, IsCorrect: true, Score: points}
+	case right > 0:
+		return Result{Verdict: VerdictPartial, Score: points * right / total}
+	}
+	return Result{Verdict: VerdictIncorrect}
+}
+
+func compact(raw json.RawMessage) string {
+	var b bytes.Buffer
+	if err := json.Compact(&b, raw); err != nil {
+		return string(raw)
+	}
+	return b.String()
+}
@@ -0,0 +1,176 @@
+package grading
+
+import (
+	"encoding/json"
+	"english-learning-app/internal/models"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGradeExercise(t *testing.T) {
+	multipleChoice := &models.Exercise{
+		Type:          models.ExerciseMultipleChoice,
+		Options:       []string{"its", "it's"},
+		CorrectAnswer: "it's",
+		Points:        10,
+	}
+	multiSelect := &models.Exercise{
+		Type:    models.ExerciseMultiSelect,
+		Options: []string{"cat", "dog", "table", "chair"},
+		Payload: &models.ExercisePayload{Correct: []string{"cat", "dog"}},
+		Points:  10,
+	}
+	matching := &models.Exercise{
+		Type: models.ExerciseMatching,
+		Payload: &models.ExercisePayload{Pairs: []models.MatchingPair{
+			{Left: "cat", Right: "Katze"}, {Left: "dog", Right: "Hund"},
+		}},
+		Points: 10,
+	}
+	wordOrder := &models.Exercise{
+		Type:            models.ExerciseWordOrder,
+		CorrectAnswer:   "I am here now",
+		AcceptedAnswers: []string{"now I am here"},
+		Points:          10,
+	}
+	trueFalse := &models.Exercise{Type: models.ExerciseTrueFalse, CorrectAnswer: "true", Points: 10}
+	fillBlank := &models.Exercise{Type: models.ExerciseFillBlank, CorrectAnswer: "because", Points: 10}
+
+	correct := Result{Verdict: VerdictCorrect, IsCorrect: true, Score: 10}
+	incorrect := Result{Verdict: VerdictIncorrect}
+	half := Result{Verdict: VerdictPartial, Score: 5}
+
+	tests := []struct {
+		name     string
+		exercise *models.Exercise
+		answer   string
+		want     Result
+		stored   string
+	}{
+		{"multiple choice", multipleChoice, `"it's"`, correct, "it's"},
+		{"multiple choice surrounding space", multipleChoice, `" it's "`, correct, " it's "},
+		// Normalizing would make the two options equal
+		{"multiple choice other option", multipleChoice, `"its"`, incorrect, "its"},
+		{"multiple choice case", multipleChoice, `"It's"`, incorrect, "It's"},
+		{"multiple choice curly quote", multipleChoice, `"it’s"`, incorrect, "it’s"},
+		{"multiple choice typo", multipleChoice, `"it'ss"`, incorrect, "it'ss"},
+
+		{"multi-select all", multiSelect, `["dog", "cat"]`, correct, `["dog","cat"]`},
+		{"multi-select some", multiSelect, `["cat"]`, half, `["cat"]`},
+		{"multi-select one too many", multiSelect, `["cat", "dog", "table"]`, half, `["cat","dog","table"]`},
+		{"multi-select everything", multiSelect, `["cat", "dog", "table", "chair"]`, incorrect, `["cat","dog","table","chair"]`},
+		{"multi-select repeated", multiSelect, `["cat", "cat"]`, half, `["cat","cat"]`},
+		{"multi-select wrong only", multiSelect, `["table"]`, incorrect, `["table"]`},
+		{"multi-select nothing", multiSelect, `[]`, incorrect, `[]`},
+		{"multi-select case", multiSelect, `["Cat", "dog"]`, incorrect, `["Cat","dog"]`},
+
+		{"matching", matching, `{"cat": "katze", "dog": "hund"}`, correct, `{"cat":"katze","dog":"hund"}`},
+		{"matching half", matching, `{"cat": "Hund", "dog": "Hund"}`, half, `{"cat":"Hund","dog":"Hund"}`},
+
+		{"word order", wordOrder, `["I", "am", "here", "now"]`, correct, "I am here now"},
+		{"word order accepted", wordOrder, `["now", "I", "am", "here"]`, correct, "now I am here"},
+		{"word order wrong", wordOrder, `["I", "here", "am", "now"]`, incorrect, "I here am now"},
+
+		{"true/false", trueFalse, `true`, correct, "true"},
+		{"true/false wrong", trueFalse, `false`, incorrect, "false"},
+
+		{"fill blank", fillBlank, `"Because"`, correct, "Because"},
+		{"fill blank typo", fillBlank, `"becase"`, Result{Verdict: VerdictTypo, IsCorrect: true, Score: 5, Corrected: "because"}, "becase"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, stored, err := GradeExercise(tt.exercise, json.RawMessage(tt.answer), lenient)
+			if err != nil {
+				t.Fatalf("GradeExercise(%s): %v", tt.answer, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GradeExercise(%s) = %+v, want %+v", tt.answer, got, tt.want)
+			}
+			if stored != tt.stored {
+				t.Errorf("GradeExercise(%s) stored %q, want %q", tt.answer, stored, tt.stored)
+			}
+		})
+	}
+}
+
+func TestGradeExerciseInvalidAnswer(t *testing.T) {
+	tests := []struct {
+		name     string
+		exercise *models.Exercise
+		answer   string
+	}{
+		{"true/false as text", &models.Exercise{Type: models.ExerciseTrueFalse, CorrectAnswer: "true"}, `"true"`},
+		{"multi-select as text", &models.Exercise{Type: models.ExerciseMultiSelect, Payload: &models.ExercisePayload{Correct: []string{"a"}}}, `"a"`},
+		{"matching as list", &models.Exercise{Type: models.ExerciseMatching, Payload: &models.ExercisePayload{}}, `["a"]`},
+		{"cloze with too few blanks", clozeExercise(), `["have", "cat"]`},
+		{"cloze as text", clozeExercise(), `"have cat home"`},
+		{"text as list", &models.Exercise{Type: models.ExerciseFillBlank, CorrectAnswer: "because"}, `["because"]`},
+		{"multiple choice as number", &models.Exercise{Type: models.ExerciseMultipleChoice, CorrectAnswer: "1"}, `1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := GradeExercise(tt.exercise, json.RawMessage(tt.answer), lenient); !errors.Is(err, ErrInvalidAnswer) {
+				t.Errorf("GradeExercise(%s) error = %v, want ErrInvalidAnswer", tt.answer, err)
+			}
+		})
+	}
+}
+
+// clozeExercise has three blanks worth 10 points, the second with a word bank.
+func clozeExercise() *models.Exercise {
+	return &models.Exercise{
+		Type:     models.ExerciseCloze,
+		Question: "I {1} a {2} at {3}.",
+		Payload: &models.ExercisePayload{Blanks: []models.ClozeBlank{
+			{Answers: []string{"have"}},
+			{Answers: []string{"cat"}, Options: []string{"cat", "cut", "cot"}},
+			{Answers: []string{"home"}},
+		}},
+		Points: 10,
+	}
+}
+
+func TestGradeCloze(t *testing.T) {
+	tests := []struct {
+		name      string
+		filled    []string
+		verdict   string
+		isCorrect bool
+		score     int
+		wrong     []int
+	}{
+		{"all right", []string{"have", "cat", "home"}, VerdictCorrect, true, 10, nil},
+		// 20 of 30 per-blank points, rounded down
+		{"one wrong", []string{"have", "cut", "home"}, VerdictPartial, false, 6, []int{2}},
+		{"two wrong", []string{"had", "cut", "home"}, VerdictPartial, false, 3, []int{1, 2}},
+		// 25 of 30, with half credit for the typo
+		{"typo in a free blank", []string{"have", "cat", "hom"}, VerdictTypo, true, 8, nil},
+		{"typo in a word bank", []string{"have", "catt", "home"}, VerdictPartial, false, 6, []int{2}},
+		{"word bank is case sensitive", []string{"have", "Cat", "home"}, VerdictPartial, false, 6, []int{2}},
+		{"word bank ignores surrounding space", []string{"have", " cat ", "home"}, VerdictCorrect, true, 10, nil},
+		{"none right", []string{"", "cot", "school"}, VerdictIncorrect, false, 0, []int{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := gradeCloze(clozeExercise(), tt.filled, lenient)
+			if got.Verdict != tt.verdict || got.IsCorrect != tt.isCorrect || got.Score != tt.score {
+				t.Errorf("gradeCloze(%q) = %s, %v, %d; want %s, %v, %d",
+					tt.filled, got.Verdict, got.IsCorrect, got.Score, tt.verdict, tt.isCorrect, tt.score)
+			}
+			if wrong := got.WrongBlanks(); !reflect.DeepEqual(wrong, tt.wrong) {
+				t.Errorf("gradeCloze(%q) wrong blanks = %v, want %v", tt.filled, wrong, tt.wrong)
+			}
+		})
+	}
+}
+
+func TestGradeClozeUnevenPoints(t *testing.T) {
+	exercise := clozeExercise()
+	exercise.Points = 7
+	// Each blank is graded out of 7; one right earns 7/3, rounded down
+	got := gradeCloze(exercise, []string{"have", "cut", "school"}, lenient)
+	if got.Score != 2 || got.Verdict != VerdictPartial {
+		t.Errorf("gradeCloze = %s, %d; want partial, 2", got.Verdict, got.Score)
+	}
+}
@@ -0,0 +1,229 @@
+// Package grading checks answers to exercises. Free-text answers and accepted
+// answers are normalized the same way before comparison, and near misses
+// within a small edit distance are accepted as typos for partial credit.
+// Structured exercise types are validated and graded in exercise.go.
+package grading
+
+import (
+	"english-learning-app/internal/config"
+	"strings"
+	"unicode"
+)
+
+// Verdicts.
+const (
+	VerdictCorrect   = "correct"
+	VerdictTypo      = "typo"
+	VerdictIncorrect = "incorrect"
+)
+
+// Options selects the normalization steps and typo tolerance.
+type Options struct {
+	IgnoreCase         bool
+	IgnorePunctuation  bool
+	CollapseWhitespace bool
+	NormalizeQuotes    bool
+	ExpandContractions bool
+	// Edits tolerated as typos, further limited to one per four characters
+	// of the accepted answer. Zero disables typo tolerance.
+	MaxTypoDistance int
+	// Share of the points awarded for an answer with typos, in percent
+	TypoCreditPercent int
+}
+
+func OptionsFromConfig(cfg *config.Config) Options {
+	return Options{
+		IgnoreCase:         cfg.Grading.IgnoreCase,
+		IgnorePunctuation:  cfg.Grading.IgnorePunctuation,
+		CollapseWhitespace: cfg.Grading.CollapseWhitespace,
+		NormalizeQuotes:    cfg.Grading.NormalizeQuotes,
+		ExpandContractions: cfg.Grading.ExpandContractions,
+		MaxTypoDistance:    cfg.Grading.MaxTypoDistance,
+		TypoCreditPercent:  cfg.Grading.TypoCreditPercent,
+	}
+}
+
+// Result is the outcome of grading one answer.
+type Result struct {
+	Verdict   string `json:"verdict"`
+	IsCorrect bool   `json:"is_correct"` // true for typos too
+	Score     int    `json:"score"`
+	// The accepted answer a typo was matched against
+	Corrected string `json:"corrected,omitempty"`
+	// Per-blank results of a cloze exercise
+	Blanks []BlankResult `json:"blanks,omitempty"`
+}
+
+// BlankResult is the outcome of one blank of a cloze exercise.
+type BlankResult struct {
+	Blank     int    `json:"blank"` // numbered from 1, as in the question
+	Verdict   string `json:"verdict"`
+	IsCorrect bool   `json:"is_correct"`
+	Corrected string `json:"corrected,omitempty"`
+}
+
+// WrongBlanks returns the numbers of the blanks answered wrongly.
+func (r Result) WrongBlanks() []int {
+	var wrong []int
+	for _, blank := range r.Blanks {
+		if !blank.IsCorrect {
+			wrong = append(wrong, blank.Blank)
+		}
+	}
+	return wrong
+}
+
+var quotes = strings.NewReplacer(
+	"‘", "'", "’", "'", "‚", "'", "‛", "'", "′", "'", "`", "'",
+	"“", `"`, "”", `"`, "„", `"`, "‟", `"`, "″", `"`,
+)
+
+// Contractions and their expansions. Ambiguous forms such as "he'd" or a
+// bare "'s" are expanded to the reading that is right most often.
+var contractions = [][2]string{
+	{"can't", "cannot"},
+	{"won't", "will not"},
+	{"shan't", "shall not"},
+	{"let's", "let us"},
+	{"it's", "it is"},
+	{"that's", "that is"},
+	{"what's", "what is"},
+	{"there's", "there is"},
+	{"here's", "here is"},
+	{"where's", "where is"},
+	{"who's", "who is"},
+	{"he's", "he is"},
+	{"she's", "she is"},
+	{"n't", " not"},
+	{"'re", " are"},
+	{"'m", " am"},
+	{"'ll", " will"},
+	{"'ve", " have"},
+	{"'d", " would"},
+}
+
+// Normalize applies the normalization steps selected in opts to s.
+func Normalize(s string, opts Options) string {
+	if opts.NormalizeQuotes {
+		s = quotes.Replace(s)
+	}
+	if opts.IgnoreCase {
+		s = strings.ToLower(s)
+	}
+	if opts.ExpandContractions {
+		s = expandContractions(s, opts.IgnoreCase)
+	}
+	if opts.IgnorePunctuation {
+		s = strings.Map(func(r rune) rune {
+			if unicode.IsPunct(r) || unicode.IsSymbol(r) {
+				return ' '
+			}
+			return r
+		}, s)
+	}
+	if opts.CollapseWhitespace {
+		s = strings.Join(strings.Fields(s), " ")
+	} else {
+		s = strings.TrimSpace(s)
+	}
+	return s
+}
+
+func expandContractions(s string, caseFolded bool) string {
+	words := strings.Fields(s)
+	for i, word := range words {
+		// Keep trailing punctuation such as "don't." out of the match
+		core := strings.TrimRightFunc(word, func(r rune) bool { return r != '\'' && unicode.IsPunct(r) })
+		tail := word[len(core):]
+		lower := core
+		if !caseFolded {
+			lower = strings.ToLower(core)
+		}
+		for _, c := range contractions {
+			if strings.HasPrefix(c[0], "'") || strings.HasPrefix(c[0], "n'") {
+				if strings.HasSuffix(lower, c[0]) && len(lower) > len(c[0]) {
+					words[i] = core[:len(core)-len(c[0])] + c[1] + tail
+					break
+				}
+				continue
+			}
+			if lower == c[0] {
+				words[i] = c[1] + tail
+				break
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Grade checks answer against the accepted answers of an exercise worth
+// points. Typos are only tolerated where typoTolerant is set, e.g. not for
+// word order.
+func Grade(answer string, accepted []string, points int, typoTolerant bool, opts Options) Result {
+	given := Normalize(answer, opts)
+	if given == "" {
+		return Result{Verdict: VerdictIncorrect}
+	}
+
+	best, bestDistance := "", -1
+	for _, candidate := range accepted {
+		expected := Normalize(candidate, opts)
+		if expected == "" {
+			continue
+		}
+		if given == expected {
+			return Result{Verdict: VerdictCorrect, IsCorrect: true, Score: points}
+		}
+		if !typoTolerant {
+			continue
+		}
+
+		distance := Levenshtein(given, expected)
+		if distance <= allowedTypos(expected, opts) && (bestDistance < 0 || distance < bestDistance) {
+			best, bestDistance = candidate, distance
+		}
+	}
+
+	if bestDistance < 0 {
+		return Result{Verdict: VerdictIncorrect}
+	}
+	return Result{
+		Verdict:   VerdictTypo,
+		IsCorrect: true,
+		Score:     points * opts.TypoCreditPercent / 100,
+		Corrected: strings.TrimSpace(best),
+	}
+}
+
+// allowedTypos scales the tolerated edit distance with the answer length so
+// short words cannot turn into other words.
+func allowedTypos(expected string, opts Options) int {
+	allowed := len([]rune(expected)) / 4
+	if allowed > opts.MaxTypoDistance {
+		allowed = opts.MaxTypoDistance
+	}
+	return allowed
+}
+
+// Levenshtein returns the number of single-character insertions, deletions
+// and substitutions turning a into b.
+func Levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,148 @@
+package grading
+
+import (
+	"reflect"
+	"testing"
+)
+
+// lenient enables every normalization step, as the default configuration does.
+var lenient = Options{
+	IgnoreCase:         true,
+	IgnorePunctuation:  true,
+	CollapseWhitespace: true,
+	NormalizeQuotes:    true,
+	ExpandContractions: true,
+	MaxTypoDistance:    2,
+	TypoCreditPercent:  50,
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		opts Options
+		want string
+	}{
+		{"nothing selected trims", "  Hello,  World! ", Options{}, "Hello,  World!"},
+		{"case", "Hello", Options{IgnoreCase: true}, "hello"},
+		{"punctuation", "Hello, world!", Options{IgnorePunctuation: true}, "Hello  world"},
+		{"whitespace", " a \t b\n c ", Options{CollapseWhitespace: true}, "a b c"},
+		{"curly quotes", "It’s “fine”", Options{NormalizeQuotes: true}, `It's "fine"`},
+		{"contractions", "I'm here", Options{ExpandContractions: true}, "I am here"},
+		{"everything", "  It’s   NOT  ok!! ", lenient, "it is not ok"},
+		{"curly contraction", "Don’t", lenient, "do not"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.in, tt.opts); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandContractions(t *testing.T) {
+	tests := []struct {
+		in         string
+		caseFolded bool
+		want       string
+	}{
+		// Whole-word forms are listed before the "n't" suffix
+		{"won't", true, "will not"},
+		{"can't", true, "cannot"},
+		{"shan't", true, "shall not"},
+		{"don't", true, "do not"},
+		{"isn't", true, "is not"},
+		{"Won't", false, "will not"},
+		{"Don't", false, "Do not"},
+		{"They're", false, "They are"},
+		{"don't.", true, "do not."},
+		{"it's mine", true, "it is mine"},
+		{"we'll we've i'd", true, "we will we have i would"},
+		// A bare suffix is not a contraction
+		{"n't 're", true, "n't 're"},
+		// Possessives are left alone
+		{"john's", true, "john's"},
+	}
+	for _, tt := range tests {
+		if got := expandContractions(tt.in, tt.caseFolded); got != tt.want {
+			t.Errorf("expandContractions(%q, %v) = %q, want %q", tt.in, tt.caseFolded, got, tt.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"same", "same", 0},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"because", "becuase", 2},
+		// Runes, not bytes
+		{"café", "cafe", 1},
+	}
+	for _, tt := range tests {
+		if got := Levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("Levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := Levenshtein(tt.b, tt.a); got != tt.want {
+			t.Errorf("Levenshtein(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestGrade(t *testing.T) {
+	tests := []struct {
+		name         string
+		answer       string
+		accepted     []string
+		typoTolerant bool
+		want         Result
+	}{
+		{"exact", "because", []string{"because"}, true,
+			Result{Verdict: VerdictCorrect, IsCorrect: true, Score: 10}},
+		{"normalized", " Because! ", []string{"because"}, true,
+			Result{Verdict: VerdictCorrect, IsCorrect: true, Score: 10}},
+		{"contraction against expansion", "I won't go", []string{"I will not go"}, true,
+			Result{Verdict: VerdictCorrect, IsCorrect: true, Score: 10}},
+		{"accepted alternative", "colour", []string{"color", "colour"}, true,
+			Result{Verdict: VerdictCorrect, IsCorrect: true, Score: 10}},
+		{"empty", "  ", []string{"because"}, true,
+			Result{Verdict: VerdictIncorrect}},
+		{"one typo", "becase", []string{"because"}, true,
+			Result{Verdict: VerdictTypo, IsCorrect: true, Score: 5, Corrected: "because"}},
+		{"too many typos for the length", "becuase", []string{"because"}, true,
+			Result{Verdict: VerdictIncorrect}},
+		{"no typos in short words", "cot", []string{"cat"}, true,
+			Result{Verdict: VerdictIncorrect}},
+		{"one typo in four letters", "hom", []string{"home"}, true,
+			Result{Verdict: VerdictTypo, IsCorrect: true, Score: 5, Corrected: "home"}},
+		{"capped by MaxTypoDistance", "intrnatonalsation", []string{"internationalisation"}, true,
+			Result{Verdict: VerdictIncorrect}},
+		{"closest accepted answer", "colr", []string{"colour", "color"}, true,
+			Result{Verdict: VerdictTypo, IsCorrect: true, Score: 5, Corrected: "color"}},
+		{"typos not tolerated", "becase", []string{"because"}, false,
+			Result{Verdict: VerdictIncorrect}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Grade(tt.answer, tt.accepted, 10, tt.typoTolerant, lenient)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Grade(%q) = %+v, want %+v", tt.answer, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGradeWithoutTypoTolerance(t *testing.T) {
+	opts := lenient
+	opts.MaxTypoDistance = 0
+	if got := Grade("necesary", []string{"necessary"}, 10, true, opts); got.Verdict != VerdictIncorrect {
+		t.Errorf("Grade with MaxTypoDistance 0 = %+v, want incorrect", got)
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"english-learning-app/internal/database"
+	"english-learning-app/internal/models"
+	"english-learning-app/pkg/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const maxAccessTokenLifetimeDays = 365
+
+func GetAccessTokens(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var tokens []models.PersonalAccessToken
+	if err := database.DB.Where("user_id = ? AND revoked_at IS NULL", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateAccessToken issues a personal access token. The plain token is only
+// returned in this response.
+func CreateAccessToken(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Name          string   `json:"name" binding:"required"`
+		Scopes        []string `json:"scopes" binding:"required,min=1"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > maxAccessTokenLifetimeDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days must be between 1 and 365, or 0 for the maximum"})
+		return
+	}
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = maxAccessTokenLifetimeDays
+	}
+
+	// A token can only be scoped to permissions its owner holds
+	roles, _ := c.Get("user_roles")
+	for _, scope := range req.Scopes {
+		if !models.IsValidPermission(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scope", "scope": scope})
+			return
+		}
+		if !models.HasPermission(roles.([]string), scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have the permission for this scope", "scope": scope})
+			return
+		}
+	}
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	token := models.PersonalAccessTokenPrefix + secret
+
+	expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+	pat := models.PersonalAccessToken{
+		UserID:      userID.(uuid.UUID),
+		Name:        req.Name,
+		TokenHash:   utils.HashToken(token),
+		TokenPrefix: token[:len(models.PersonalAccessTokenPrefix)+6],
+		Scopes:      req.Scopes,
+		ExpiresAt:   &expiresAt,
+	}
+
+	if err := database.DB.Create(&pat).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":        token,
+		"access_token": pat,
+	})
+}
+
+func RevokeAccessToken(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	result := database.DB.Model(&models.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", c.Param("id"), userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked successfully"})
+}
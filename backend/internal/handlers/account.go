@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"bytes"
+	"english-learning-app/internal/account"
+	"english-learning-app/internal/config"
+	"english-learning-app/internal/database"
+	"english-learning-app/internal/mailer"
+	"english-learning-app/internal/models"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ExportAccountData returns a zip archive with all personal data held about
+// the current user.
+func ExportAccountData(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id := userID.(uuid.UUID)
+
+	// Build the archive in memory so a failure can still be reported as JSON
+	var buf bytes.Buffer
+	if err := account.Export(database.DB, id, &buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export account data"})
+		return
+	}
+
+	recordSecurityEvent(c, models.SecurityEventDataExported, &id, c.GetString("user_email"), "")
+
+	filename := fmt.Sprintf("enup-export-%s.zip", time.Now().UTC().Format("2006-01-02"))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// DeleteAccount schedules the account for deletion after the grace period.
+// All sessions and access tokens are revoked right away; logging in again and
+// calling CancelAccountDeletion keeps the account. The user confirms with
+// their password, or, for accounts without one such as OpenID Connect only
+// accounts, by having logged in again within the last few minutes.
+func DeleteAccount(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.DeletionDueAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Account is already scheduled for deletion"})
+		return
+	}
+
+	cfg := config.LoadConfig()
+
+	if req.Password == "" {
+		// A fresh login already checked every factor of the account
+		sessionID, _ := c.Get("session_id")
+		var session models.Session
+		err := database.DB.Where("id = ? AND user_id = ?", sessionID, user.ID).First(&session).Error
+		if err != nil || time.Since(session.CreatedAt) > time.Duration(cfg.Account.ReauthMaxAgeMinutes)*time.Minute {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Enter your password or log in again to delete your account", "reauth_required": true})
+			return
+		}
+	} else if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	if req.Password != "" && user.MFAEnabled {
+		_, ok, err := verifySecondFactor(database.DB, user.ID, req.Code, req.RecoveryCode)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+			return
+		}
+		if !ok {
+			recordSecurityEvent(c, models.SecurityEventMFAFailed, &user.ID, user.Email, "delete account")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+			return
+		}
+	}
+
+	dueAt := time.Now().AddDate(0, 0, cfg.Account.DeletionGraceDays)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("deletion_due_at", dueAt).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.PersonalAccessToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return revokeSessions(tx, "user_id = ?", user.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+
+	recordSecurityEvent(c, models.SecurityEventDeletionQueued, &user.ID, user.Email, "due "+dueAt.UTC().Format(time.RFC3339))
+
+	if err := mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your account will be deleted",
+		Body: fmt.Sprintf("Hi %s,\n\nYour %s account and all of its data will be permanently deleted on %s.\n\nIf you change your mind, log in before then and cancel the deletion from your account settings.\n",
+			user.Name, cfg.App.Name, dueAt.UTC().Format("January 2, 2006")),
+	}); err != nil {
+		log.Printf("Failed to send account deletion email to %s: %v", user.Email, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "Account scheduled for deletion",
+		"deletion_due_at": dueAt,
+	})
+}
+
+func CancelAccountDeletion(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	result := database.DB.Model(&models.User{}).
+		Where("id = ? AND deletion_due_at IS NOT NULL", userID).
+		Update("deletion_due_at", nil)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel account deletion"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Account is not scheduled for deletion"})
+		return
+	}
+
+	id := userID.(uuid.UUID)
+	recordSecurityEvent(c, models.SecurityEventDeletionCancel, &id, c.GetString("user_email"), "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deletion cancelled successfully"})
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"english-learning-app/internal/achievements"
+	"english-learning-app/internal/database"
+	"english-learning-app/internal/models"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AchievementRequest struct {
+	Code        *string                     `json:"code"`
+	Name        string                      `json:"name" binding:"required"`
+	Description string                      `json:"description"`
+	Icon        string                      `json:"icon"`
+	Points      int                         `json:"points" binding:"min=0"`
+	Criteria    *models.AchievementCriteria `json:"criteria"`
+	IsActive    *bool                       `json:"is_active"`
+}
+
+type UserAchievementEntry struct {
+	models.Achievement
+	Earned    bool       `json:"earned"`
+	GrantedAt *time.Time `json:"granted_at"`
+}
+
+// validAchievementRequest normalizes the request and responds with an error
+// if the criteria cannot be evaluated or the code belongs to another
+// achievement.
+func validAchievementRequest(c *gin.Context, req *AchievementRequest, currentID string) bool {
+	if err := achievements.Validate(req.Criteria); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	if req.Code != nil && *req.Code == "" {
+		req.Code = nil
+	}
+	if req.Code == nil {
+		return true
+	}
+
+	query := database.DB.Model(&models.Achievement{}).Where("code = ?", *req.Code)
+	if currentID != "" {
+		query = query.Where("id <> ?", currentID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check achievement code"})
+		return false
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Achievement code already in use"})
+		return false
+	}
+	return true
+}
+
+// GetAchievements lists the active achievements and which of them the
+// current user has earned.
+func GetAchievements(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var list []models.Achievement
+	if err := database.DB.Where("is_active = ?", true).Order("points, name").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievements"})
+		return
+	}
+
+	var earned []models.UserAchievement
+	if err := database.DB.Where("user_id = ?", userID).Find(&earned).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievements"})
+		return
+	}
+	grantedAt := make(map[string]time.Time, len(earned))
+	for _, e := range earned {
+		grantedAt[e.AchievementID.String()] = e.GrantedAt
+	}
+
+	entries := make([]UserAchievementEntry, 0, len(list))
+	for _, achievement := range list {
+		entry := UserAchievementEntry{Achievement: achievement}
+		if t, ok := grantedAt[achievement.ID.String()]; ok {
+			entry.Earned = true
+			entry.GrantedAt = &t
+		}
+		entries = append(entries, entry)
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+func ListAchievements(c *gin.Context) {
+	var list []models.Achievement
+	if err := database.DB.Order("created_at").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievements"})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+func CreateAchievement(c *gin.Context) {
+	var req AchievementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !validAchievementRequest(c, &req, "") {
+		return
+	}
+
+	achievement := models.Achievement{
+		Code:        req.Code,
+		Name:        req.Name,
+		Description: req.Description,
+		Icon:        req.Icon,
+		Points:      req.Points,
+		Criteria:    req.Criteria,
+		IsActive:    req.IsActive == nil || *req.IsActive,
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&achievement).Error; err != nil {
+			return err
+		}
+		// A false is_active is a zero value, which Create replaces with the
+		// column default
+		if !achievement.IsActive {
+			return tx.Model(&achievement).Update("is_active", false).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create achievement"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, achievement)
+}
+
+// UpdateAchievement replaces the achievement definition. Users who already
+// earned it keep it.
+func UpdateAchievement(c *gin.Context) {
+	var req AchievementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var achievement models.Achievement
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&achievement).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Achievement not found"})
+		return
+	}
+
+	if !validAchievementRequest(c, &req, achievement.ID.String()) {
+		return
+	}
+
+	achievement.Code = req.Code
+	achievement.Name = req.Name
+	achievement.Description = req.Description
+	achievement.Icon = req.Icon
+	achievement.Points = req.Points
+	achievement.Criteria = req.Criteria
+	if req.IsActive != nil {
+		achievement.IsActive = *req.IsActive
+	}
+
+	if err := database.DB.Save(&achievement).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update achievement"})
+		return
+	}
+
+	c.JSON(http.StatusOK, achievement)
+}
+
+// DeleteAchievement removes the achievement and every grant of it. Points
+// already awarded for it stay in the ledger.
+func DeleteAchievement(c *gin.Context) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("achievement_id = ?", c.Param("id")).Delete(&models.UserAchievement{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id = ?", c.Param("id")).Delete(&models.Achievement{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Achievement not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete achievement"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Achievement deleted successfully"})
+}
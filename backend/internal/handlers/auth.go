@@ -1,11 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"english-learning-app/internal/database"
 	"english-learning-app/internal/models"
 	"english-learning-app/internal/config"
-	"english-learning-app/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
@@ -15,11 +16,19 @@ type RegisterRequest struct {
 	Name     string `json:"name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
+	// Optional name for the new session, e.g. "Work laptop"
+	DeviceLabel string `json:"device_label"`
 }
 
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	// Optional name for the new session, e.g. "Work laptop"
+	DeviceLabel string `json:"device_label"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
 type AuthResponse struct {
@@ -64,28 +73,22 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	// Generate tokens
 	cfg := config.LoadConfig()
-	accessToken, err := utils.GenerateToken(user.ID, user.Email, cfg.JWT.SecretKey, cfg.JWT.AccessTokenExpiry)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
+
+	// The account is usable right away; verification only unlocks the
+	// features listed in the verification policy
+	if err := sendVerificationEmail(cfg, &user); err != nil {
+		log.Printf("Failed to send verification email to %s: %v", user.Email, err)
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(user.ID, cfg.JWT.SecretKey, cfg.JWT.RefreshTokenExpiry)
+	// Generate tokens
+	response, err := startSession(database.DB, c, cfg, &user, req.DeviceLabel)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	// Clear password from response
-	user.Password = ""
-
-	c.JSON(http.StatusCreated, AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		User:         user,
-	})
+	c.JSON(http.StatusCreated, response)
 }
 
 func Login(c *gin.Context) {
@@ -95,44 +98,62 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	if loginThrottled(c, req.Email) {
+		return
+	}
+
 	// Find user
 	var user models.User
 	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		recordLoginFailure(c, req.Email, nil)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	// Check password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		recordLoginFailure(c, req.Email, &user.ID)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	// Generate tokens
+	recordLoginSuccess(req.Email)
+
 	cfg := config.LoadConfig()
-	accessToken, err := utils.GenerateToken(user.ID, user.Email, cfg.JWT.SecretKey, cfg.JWT.AccessTokenExpiry)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+
+	// Accounts with two-factor authentication get their tokens from VerifyMFA
+	if user.MFAEnabled {
+		respondMFAChallenge(c, cfg, &user)
 		return
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(user.ID, cfg.JWT.SecretKey, cfg.JWT.RefreshTokenExpiry)
+	// Generate tokens
+	response, err := startSession(database.DB, c, cfg, &user, req.DeviceLabel)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	// Clear password from response
-	user.Password = ""
-
-	c.JSON(http.StatusOK, AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		User:         user,
-	})
+	c.JSON(http.StatusOK, response)
 }
 
 func RefreshToken(c *gin.Context) {
-	// Implementation for refresh token
-	c.JSON(http.StatusOK, gin.H{"message": "Refresh token endpoint"})
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.LoadConfig()
+	response, err := rotateRefreshToken(c, cfg, req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, errInvalidRefreshToken) || errors.Is(err, errRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
 }
@@ -1,8 +1,13 @@
 package handlers
 
 import (
+	"encoding/json"
+	"log"
 	"net/http"
+	"time"
+	"english-learning-app/internal/achievements"
 	"english-learning-app/internal/database"
+	"english-learning-app/internal/grading"
 	"english-learning-app/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -51,6 +56,10 @@ func GetTopic(c *gin.Context) {
 		return
 	}
 
+	for i := range topic.Exercises {
+		hideAnswer(&topic.Exercises[i])
+	}
+
 	c.JSON(http.StatusOK, topic)
 }
 
@@ -64,7 +73,7 @@ func GetExercise(c *gin.Context) {
 	}
 
 	// Don't send correct answer to client
-	exercise.CorrectAnswer = ""
+	hideAnswer(&exercise)
 
 	c.JSON(http.StatusOK, exercise)
 }
@@ -78,8 +87,9 @@ func SubmitExercise(c *gin.Context) {
 
 	exerciseID := c.Param("id")
 	
+	// The answer's format depends on the exercise type, see grading.GradeExercise
 	var req struct {
-		Answer string `json:"answer" binding:"required"`
+		Answer json.RawMessage `json:"answer" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -94,20 +104,36 @@ func SubmitExercise(c *gin.Context) {
 		return
 	}
 
+	pending, err := placementPending(database.DB, userID, exercise.ID)
+	if err == nil && !pending {
+		pending, err = quizPending(database.DB, userID, exercise.ID, uuid.Nil)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check exercise"})
+		return
+	}
+	if pending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Answer this exercise in the test you have in progress"})
+		return
+	}
+
 	// Check answer
-	isCorrect := req.Answer == exercise.CorrectAnswer
-	score := 0
-	if isCorrect {
-		score = exercise.Points
+	result, answer, err := gradeAnswer(&exercise, req.Answer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Answer is not in the format of a " + exercise.Type + " exercise"})
+		return
 	}
+	isCorrect, score := result.IsCorrect, result.Score
 
 	// Save attempt
 	attempt := models.ExerciseAttempt{
 		UserID:      userID.(uuid.UUID),
 		ExerciseID:  uuid.MustParse(exerciseID),
-		Answer:      req.Answer,
+		Answer:      answer,
 		IsCorrect:   isCorrect,
 		Score:       score,
+		WrongBlanks: result.WrongBlanks(),
+		AttemptedAt: time.Now(),
 	}
 
 	if err := database.DB.Create(&attempt).Error; err != nil {
@@ -115,10 +141,25 @@ func SubmitExercise(c *gin.Context) {
 		return
 	}
 
+	joinWeeklyLeague(attempt.UserID)
+
+	unlocked, err := achievements.Evaluate(database.DB, attempt.UserID, achievements.EventExerciseAttempted)
+	if err != nil {
+		log.Printf("Failed to evaluate achievements for user %s: %v", attempt.UserID, err)
+	}
+
+	daily, streakUnlocked := recordDailyActivity(attempt.UserID)
+	unlocked = append(unlocked, streakUnlocked...)
+
 	c.JSON(http.StatusOK, gin.H{
 		"is_correct": isCorrect,
 		"score":      score,
+		"verdict":    result.Verdict,
+		"corrected":  result.Corrected,
+		"blanks":     result.Blanks,
 		"explanation": exercise.Explanation,
+		"achievements_unlocked": unlocked,
+		"daily": daily,
 	})
 }
 
@@ -130,6 +171,11 @@ func CreateLevel(c *gin.Context) {
 		return
 	}
 
+	if !validPromotionRules(&level) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Promotion rules must be percentages between 0 and 100"})
+		return
+	}
+
 	if err := database.DB.Create(&level).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create level"})
 		return
@@ -141,13 +187,26 @@ func CreateLevel(c *gin.Context) {
 func UpdateLevel(c *gin.Context) {
 	levelID := c.Param("id")
 	
+	// Fields missing from the request keep their current values
 	var level models.Level
+	if err := database.DB.Where("id = ?", levelID).First(&level).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Level not found"})
+		return
+	}
 	if err := c.ShouldBindJSON(&level); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := database.DB.Where("id = ?", levelID).Updates(&level).Error; err != nil {
+	if !validPromotionRules(&level) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Promotion rules must be percentages between 0 and 100"})
+		return
+	}
+
+	// Listed explicitly so zero values, e.g. a rule turned off, are saved too
+	if err := database.DB.Model(&models.Level{}).Where("id = ?", levelID).
+		Select("name", "title", "description", "order", "is_active", "min_topics_percent", "min_average_score").
+		Updates(&level).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update level"})
 		return
 	}
@@ -216,6 +275,11 @@ func CreateExercise(c *gin.Context) {
 		return
 	}
 
+	if err := grading.Validate(&exercise); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := database.DB.Create(&exercise).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create exercise"})
 		return
@@ -228,12 +292,25 @@ func UpdateExercise(c *gin.Context) {
 	exerciseID := c.Param("id")
 	
 	var exercise models.Exercise
+	if err := database.DB.Where("id = ?", exerciseID).First(&exercise).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exercise not found"})
+		return
+	}
+
+	// Changes are applied over the stored exercise so it is validated as a whole
+	id := exercise.ID
 	if err := c.ShouldBindJSON(&exercise); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	exercise.ID = id
+
+	if err := grading.Validate(&exercise); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	if err := database.DB.Where("id = ?", exerciseID).Updates(&exercise).Error; err != nil {
+	if err := database.DB.Save(&exercise).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update exercise"})
 		return
 	}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"english-learning-app/internal/achievements"
+	"english-learning-app/internal/config"
+	"english-learning-app/internal/database"
+	"english-learning-app/internal/models"
+	"english-learning-app/internal/points"
+	"english-learning-app/internal/streaks"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errInsufficientPoints = errors.New("insufficient points")
+
+// recordDailyActivity updates the user's streak after learning activity and
+// grants streak achievements when the streak grew. Failures are logged so
+// they never fail the activity itself.
+func recordDailyActivity(userID uuid.UUID) (*streaks.Status, []models.Achievement) {
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		log.Printf("Failed to load user %s for streak update: %v", userID, err)
+		return nil, nil
+	}
+
+	status, extended, err := streaks.Record(database.DB, &user, time.Now())
+	if err != nil {
+		log.Printf("Failed to update streak for user %s: %v", userID, err)
+		return nil, nil
+	}
+	if !extended {
+		return status, nil
+	}
+
+	unlocked, err := achievements.Evaluate(database.DB, userID, achievements.EventStreakUpdated)
+	if err != nil {
+		log.Printf("Failed to evaluate achievements for user %s: %v", userID, err)
+	}
+	return status, unlocked
+}
+
+// GetDailyStatus reports today's progress towards the daily goal and the
+// current streak.
+func GetDailyStatus(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	status, err := streaks.Today(database.DB, &user, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch daily progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+func UpdateDailyGoal(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Type   string `json:"type" binding:"required"`
+		Target int    `json:"target" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !streaks.ValidGoal(req.Type, req.Target) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Goal type must be xp or exercises with a positive target"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := database.DB.Model(&user).Updates(map[string]interface{}{
+		"daily_goal_type":   req.Type,
+		"daily_goal_target": req.Target,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update daily goal"})
+		return
+	}
+
+	// A lower goal may already be met by today's activity
+	status, _ := recordDailyActivity(user.ID)
+	if status == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch daily progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// BuyStreakFreeze spends points on a streak freeze, which covers one missed
+// day so the streak survives it.
+func BuyStreakFreeze(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	cfg := config.LoadConfig()
+
+	var freezes int
+	var bought bool
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", userID).First(&user).Error; err != nil {
+			return err
+		}
+		if user.Points < cfg.Streak.FreezeCost {
+			return errInsufficientPoints
+		}
+
+		var err error
+		freezes, bought, err = streaks.BuyFreeze(tx, &user, cfg.Streak.MaxFreezes)
+		if err != nil || !bought {
+			return err
+		}
+
+		_, err = points.Award(tx, &models.PointsEntry{
+			UserID:         user.ID,
+			SourceType:     models.PointsSourceStreakFreeze,
+			Delta:          -cfg.Streak.FreezeCost,
+			IdempotencyKey: "streak_freeze:" + uuid.New().String(),
+			Reason:         "Streak freeze",
+		})
+		return err
+	})
+	if errors.Is(err, errInsufficientPoints) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Not enough points for a streak freeze"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to buy streak freeze"})
+		return
+	}
+	if !bought {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already hold the maximum number of streak freezes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Streak freeze purchased successfully",
+		"freezes_available": freezes,
+		"cost":              cfg.Streak.FreezeCost,
+	})
+}
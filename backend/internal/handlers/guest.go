@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"english-learning-app/internal/config"
+	"english-learning-app/internal/database"
+	"english-learning-app/internal/models"
+	"english-learning-app/internal/throttle"
+	"english-learning-app/pkg/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var errEmailTaken = errors.New("email already registered")
+
+// CreateGuest starts an anonymous account so visitors can try lessons before
+// registering. The account expires once it goes unused for the guest
+// lifetime, unless it is upgraded with UpgradeGuest. Each client IP can only
+// create a limited number of guests per hour.
+func CreateGuest(c *gin.Context) {
+	var req struct {
+		DeviceLabel string `json:"device_label"`
+	}
+
+	// The body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if wait, err := throttle.GuestSignups.Check(c.ClientIP()); err != nil {
+		log.Printf("Failed to check guest throttle: %v", err)
+	} else if wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return
+	}
+
+	// Guests never log in with a password; give them an unusable one
+	randomPassword, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create guest"})
+		return
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(randomPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	cfg := config.LoadConfig()
+	id := uuid.New()
+	expiresAt := time.Now().Add(time.Duration(cfg.Account.GuestLifetimeHours) * time.Hour)
+
+	user := models.User{
+		ID:             id,
+		Name:           "Guest",
+		Email:          fmt.Sprintf("guest-%s@guest.invalid", id),
+		Password:       string(hashedPassword),
+		Level:          "A0",
+		Points:         0,
+		IsGuest:        true,
+		GuestExpiresAt: &expiresAt,
+	}
+
+	var response *AuthResponse
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		response, err = startSession(tx, c, cfg, &user, req.DeviceLabel)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create guest"})
+		return
+	}
+
+	if _, err := throttle.GuestSignups.Fail(c.ClientIP()); err != nil {
+		log.Printf("Failed to record guest creation: %v", err)
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// UpgradeGuest turns the current guest account into a full account. The user
+// ID stays the same, so progress and exercise attempts carry over.
+func UpgradeGuest(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !user.IsGuest {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Account is not a guest account"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	cfg := config.LoadConfig()
+
+	var response *AuthResponse
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Unscoped().Where("email = ?", req.Email).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errEmailTaken
+		}
+
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"name":             req.Name,
+			"email":            req.Email,
+			"password":         string(hashedPassword),
+			"is_guest":         false,
+			"guest_expires_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		user.Name = req.Name
+		user.Email = req.Email
+		user.IsGuest = false
+		user.GuestExpiresAt = nil
+
+		// The guest session was capped at the guest lifetime; replace it
+		if err := revokeSessions(tx, "user_id = ?", user.ID); err != nil {
+			return err
+		}
+
+		response, err = startSession(tx, c, cfg, &user, req.DeviceLabel)
+		return err
+	})
+	if errors.Is(err, errEmailTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upgrade account"})
+		return
+	}
+
+	if err := sendVerificationEmail(cfg, &user); err != nil {
+		log.Printf("Failed to send verification email to %s: %v", user.Email, err)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// extendGuest pushes back the expiry of a guest account in use, so only
+// abandoned guests are purged.
+func extendGuest(tx *gorm.DB, cfg *config.Config, user *models.User) error {
+	expiresAt := time.Now().Add(time.Duration(cfg.Account.GuestLifetimeHours) * time.Hour)
+	if err := tx.Model(user).Update("guest_expires_at", expiresAt).Error; err != nil {
+		return err
+	}
+	user.GuestExpiresAt = &expiresAt
+	return nil
+}
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"english-learning-app/internal/keys"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS publishes the public token signing keys so other services can
+// verify our access tokens.
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, keys.Default.JWKS())
+}
@@ -1,36 +1,201 @@
 package handlers
 
 import (
-	"net/http"
 	"english-learning-app/internal/database"
+	"english-learning-app/internal/leaderboard"
+	"english-learning-app/internal/leagues"
+	"english-learning-app/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-type LeaderboardEntry struct {
-	UserID   string `json:"user_id"`
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Level    string `json:"level"`
-	Points   int    `json:"points"`
-	TopicsCompleted int `json:"topics_completed"`
+// Leaderboard scopes.
+const (
+	scopeGlobal  = "global"
+	scopeFriends = "friends"
+	scopeClass   = "class"
+	scopeLeague  = "league"
+)
+
+type LeaderboardResponse struct {
+	Window     string              `json:"window"`
+	Since      *time.Time          `json:"since"`
+	Scope      string              `json:"scope"`
+	Level      string              `json:"level,omitempty"`
+	Entries    []leaderboard.Entry `json:"entries"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+	Me         *leaderboard.Entry  `json:"me"`
 }
 
+// GetLeaderboard ranks learners by points earned in the week, month or all
+// time. Optional filters: level, scope=friends, scope=class with class_id, or
+// scope=league for the caller's weekly league, which always uses its week.
+// Pages are fetched with the cursor from the previous response.
 func GetLeaderboard(c *gin.Context) {
-	var entries []LeaderboardEntry
-	
-	// Get top users by points
-	err := database.DB.Table("users").
-		Select("users.id as user_id, users.name, users.email, users.level, users.points, COUNT(user_progress.id) as topics_completed").
-		Joins("LEFT JOIN user_progress ON users.id = user_progress.user_id AND user_progress.completed = true").
-		Group("users.id, users.name, users.email, users.level, users.points").
-		Order("users.points DESC, topics_completed DESC").
-		Limit(50).
-		Find(&entries).Error
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	me := userID.(uuid.UUID)
 
+	window := c.DefaultQuery("window", leaderboard.WindowAll)
+	since, ok := leaderboard.WindowStart(window, time.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "window must be week, month or all"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	var after *leaderboard.Cursor
+	if cursor := c.Query("cursor"); cursor != "" {
+		var err error
+		if after, err = leaderboard.DecodeCursor(cursor); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+	}
+
+	query := &leaderboard.Query{Period: leaderboard.Period(window, since), Level: c.Query("level")}
+
+	scope := c.DefaultQuery("scope", scopeGlobal)
+	switch scope {
+	case scopeGlobal:
+	case scopeFriends:
+		query.Members = friendIDs(me)
+	case scopeClass:
+		classID, err := uuid.Parse(c.Query("class_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "class_id is required for the class scope"})
+			return
+		}
+		member, err := isClassroomMember(classID, me)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leaderboard"})
+			return
+		}
+		if !member {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this classroom"})
+			return
+		}
+		query.Members = database.DB.Model(&models.ClassroomMember{}).Select("user_id").Where("classroom_id = ?", classID)
+	case scopeLeague:
+		membership, err := leagues.Current(database.DB, me, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leaderboard"})
+			return
+		}
+		if membership == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "You have not joined a league this week"})
+			return
+		}
+		window, since = leaderboard.WindowWeek, membership.League.WeekStart
+		query.Period = leaderboard.Period(window, since)
+		query.Members = database.DB.Model(&models.LeagueMembership{}).Select("user_id").Where("league_id = ?", membership.LeagueID)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be global, friends, class or league"})
+		return
+	}
+
+	entries, err := leaderboard.Page(database.DB, query, after, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leaderboard"})
+		return
+	}
+
+	mine, err := leaderboard.RankOf(database.DB, query, me)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leaderboard"})
 		return
 	}
 
-	c.JSON(http.StatusOK, entries)
+	response := LeaderboardResponse{
+		Window:  window,
+		Scope:   scope,
+		Level:   query.Level,
+		Entries: entries,
+		Me:      mine,
+	}
+	if !since.IsZero() {
+		response.Since = &since
+	}
+	if len(entries) == limit {
+		last := entries[len(entries)-1]
+		response.NextCursor = leaderboard.Cursor{Rank: last.Rank, Points: last.Points, UserID: last.UserID}.Encode()
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// friendIDs selects the user and their accepted friends.
+func friendIDs(userID uuid.UUID) *gorm.DB {
+	return database.DB.Raw(`SELECT CASE WHEN user_id = ? THEN friend_id ELSE user_id END FROM friendships
+		WHERE status = ? AND (user_id = ? OR friend_id = ?)
+		UNION SELECT ?`,
+		userID, models.FriendshipAccepted, userID, userID, userID)
+}
+
+// leaderboardPeriod reads the period an admin request targets from window
+// and an optional date inside it, defaulting to the current one.
+func leaderboardPeriod(c *gin.Context) (string, bool) {
+	window := c.DefaultQuery("window", leaderboard.WindowAll)
+	if _, ok := leaderboard.WindowStart(window, time.Now()); !ok {
+		return "", false
+	}
+	at := time.Now()
+	if date := c.Query("date"); date != "" {
+		var err error
+		if at, err = time.Parse("2006-01-02", date); err != nil {
+			return "", false
+		}
+	}
+	return leaderboard.Period(window, at), true
+}
+
+// CheckLeaderboard compares the stored scores of a period with the points
+// ledger and lists the users that differ.
+func CheckLeaderboard(c *gin.Context) {
+	period, ok := leaderboardPeriod(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "window must be week, month or all and date YYYY-MM-DD"})
+		return
+	}
+
+	drift, err := leaderboard.Check(database.DB, period)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check leaderboard"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"period":     period,
+		"consistent": len(drift) == 0,
+		"drift":      drift,
+	})
+}
+
+// RebuildLeaderboard recomputes the stored scores of a period from the
+// points ledger.
+func RebuildLeaderboard(c *gin.Context) {
+	period, ok := leaderboardPeriod(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "window must be week, month or all and date YYYY-MM-DD"})
+		return
+	}
+
+	if err := leaderboard.Rebuild(database.DB, period); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rebuild leaderboard"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Leaderboard rebuilt successfully", "period": period})
 }
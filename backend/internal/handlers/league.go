@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"english-learning-app/internal/config"
+	"english-learning-app/internal/database"
+	"english-learning-app/internal/leaderboard"
+	"english-learning-app/internal/leagues"
+	"english-learning-app/internal/models"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type LeagueResponse struct {
+	models.League
+	TierName string    `json:"tier_name"`
+	WeekEnd  time.Time `json:"week_end"`
+	// Ranks at or above PromoteUntil move up a tier if the week ended now;
+	// ranks at or below RelegateFrom move down. Zero when nobody would.
+	PromoteUntil int                 `json:"promote_until"`
+	RelegateFrom int                 `json:"relegate_from"`
+	Standings    []leaderboard.Entry `json:"standings"`
+	Me           *leaderboard.Entry  `json:"me"`
+}
+
+type LeagueHistoryEntry struct {
+	models.LeagueMembership
+	TierName     string `json:"tier_name"`
+	NextTierName string `json:"next_tier_name,omitempty"`
+}
+
+// joinWeeklyLeague enters the learner into this week's league after activity
+// that earns points. Failures are logged so they never fail the activity.
+func joinWeeklyLeague(userID uuid.UUID) {
+	var user models.User
+	if err := database.DB.Select("id", "is_guest").Where("id = ?", userID).First(&user).Error; err != nil {
+		log.Printf("Failed to load user %s for weekly league: %v", userID, err)
+		return
+	}
+	if user.IsGuest {
+		return
+	}
+	if _, err := leagues.Join(database.DB, user.ID, time.Now(), leagues.PolicyFromConfig(config.LoadConfig())); err != nil {
+		log.Printf("Failed to join weekly league for user %s: %v", userID, err)
+	}
+}
+
+// GetCurrentLeague returns the caller's league for this week with live
+// standings. Learners join a league with their first activity of the week.
+func GetCurrentLeague(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	me := userID.(uuid.UUID)
+
+	cfg := config.LoadConfig()
+	policy := leagues.PolicyFromConfig(cfg)
+
+	membership, err := leagues.Current(database.DB, me, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch league"})
+		return
+	}
+	if membership == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You have not joined a league this week"})
+		return
+	}
+
+	league := membership.League
+	standings, err := leagues.Standings(database.DB, league)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch league"})
+		return
+	}
+
+	response := LeagueResponse{
+		League:    *league,
+		TierName:  leagues.TierName(league.Tier),
+		WeekEnd:   league.WeekStart.AddDate(0, 0, 7),
+		Standings: standings,
+	}
+	for i := range standings {
+		entry := &standings[i]
+		outcome, _ := policy.Outcome(league.Tier, entry.Rank, len(standings), entry.Points)
+		switch outcome {
+		case models.LeagueOutcomePromoted:
+			response.PromoteUntil = entry.Rank
+		case models.LeagueOutcomeRelegated:
+			if response.RelegateFrom == 0 {
+				response.RelegateFrom = entry.Rank
+			}
+		}
+		if entry.UserID == me {
+			response.Me = entry
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetLeagueHistory lists the caller's weekly leagues, newest first, with
+// their final rank and outcome once the week is closed.
+func GetLeagueHistory(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var memberships []models.LeagueMembership
+	if err := database.DB.Preload("League").
+		Where("user_id = ?", userID).
+		Order("week_start DESC").
+		Find(&memberships).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch league history"})
+		return
+	}
+
+	history := make([]LeagueHistoryEntry, 0, len(memberships))
+	for _, m := range memberships {
+		entry := LeagueHistoryEntry{LeagueMembership: m, TierName: leagues.TierName(m.Tier)}
+		if m.NextTier != nil {
+			entry.NextTierName = leagues.TierName(*m.NextTier)
+		}
+		history = append(history, entry)
+	}
+
+	c.JSON(http.StatusOK, history)
+}
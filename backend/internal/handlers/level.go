@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"english-learning-app/internal/database"
+	"english-learning-app/internal/models"
+	"english-learning-app/internal/progression"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type LevelProgressResponse struct {
+	Level     string                    `json:"level"`
+	NextLevel string                    `json:"next_level,omitempty"`
+	Progress  *progression.Stats        `json:"progress"`
+	LevelUps  []models.UserLevelHistory `json:"level_ups"` // not yet acknowledged
+}
+
+// validPromotionRules reports whether the promotion rules of a level are
+// percentages.
+func validPromotionRules(level *models.Level) bool {
+	return level.MinTopicsPercent >= 0 && level.MinTopicsPercent <= 100 &&
+		level.MinAverageScore >= 0 && level.MinAverageScore <= 100
+}
+
+// pendingLevelUps returns the user's level changes they have not seen yet.
+func pendingLevelUps(userID interface{}) ([]models.UserLevelHistory, error) {
+	levelUps := []models.UserLevelHistory{}
+	err := database.DB.Where("user_id = ? AND acknowledged_at IS NULL", userID).Order("created_at").Find(&levelUps).Error
+	return levelUps, err
+}
+
+// GetLevelProgress reports how close the user is to leaving their current
+// level and any level-ups still to be shown.
+func GetLevelProgress(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	response := LevelProgressResponse{Level: user.Level}
+
+	var level models.Level
+	err := database.DB.Where("name = ?", user.Level).First(&level).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch level progress"})
+		return
+	}
+	if err == nil {
+		if response.Progress, err = progression.Measure(database.DB, user.ID, &level); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch level progress"})
+			return
+		}
+
+		var following models.Level
+		if err := database.DB.Where(`is_active = ? AND "order" > ?`, true, level.Order).Order(`"order"`).First(&following).Error; err == nil {
+			response.NextLevel = following.Name
+		}
+	}
+
+	if response.LevelUps, err = pendingLevelUps(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch level progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func GetLevelHistory(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var history []models.UserLevelHistory
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch level history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// AcknowledgeLevelUp marks a level-up as shown to the user.
+func AcknowledgeLevelUp(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var change models.UserLevelHistory
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&change).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Level change not found"})
+		return
+	}
+
+	if change.AcknowledgedAt == nil {
+		if err := database.DB.Model(&change).Update("acknowledged_at", time.Now()).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to acknowledge level change"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, change)
+}
@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"english-learning-app/internal/config"
+	"english-learning-app/internal/database"
+	"english-learning-app/internal/keys"
+	"english-learning-app/internal/models"
+	"english-learning-app/internal/throttle"
+	"english-learning-app/pkg/utils"
+	"errors"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	recoveryCodeCount    = 10
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	// Accept codes from one step before and after the current one to allow
+	// for clock drift on the phone
+	totpSkew = 1
+)
+
+var errInvalidMFACode = errors.New("invalid two-factor code")
+
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"` // seconds
+}
+
+// respondMFAChallenge answers a successful password login for an account with
+// two-factor authentication by returning a pending token instead of tokens.
+func respondMFAChallenge(c *gin.Context, cfg *config.Config, user *models.User) {
+	token, jti, err := utils.GenerateMFAToken(user.ID, keys.Default, cfg.JWT.MFATokenExpiry)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	// The token's ID is recorded so it can only be exchanged once
+	pending := models.UserToken{
+		UserID:    user.ID,
+		Purpose:   models.TokenPurposeMFAPending,
+		TokenHash: utils.HashToken(jti),
+		ExpiresAt: time.Now().Add(time.Duration(cfg.JWT.MFATokenExpiry) * time.Minute),
+	}
+	if err := database.DB.Create(&pending).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MFAChallengeResponse{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   cfg.JWT.MFATokenExpiry * 60,
+	})
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// generateRecoveryCodes replaces the user's recovery codes and returns the new
+// codes in plain text. They are shown to the user exactly once.
+func generateRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.MFARecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		var b strings.Builder
+		for j := 0; j < 10; j++ {
+			if j == 5 {
+				b.WriteByte('-')
+			}
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(recoveryCodeAlphabet))))
+			if err != nil {
+				return nil, err
+			}
+			b.WriteByte(recoveryCodeAlphabet[n.Int64()])
+		}
+
+		code := b.String()
+		codes = append(codes, code)
+		records = append(records, models.MFARecoveryCode{
+			UserID:   userID,
+			CodeHash: utils.HashToken(normalizeRecoveryCode(code)),
+		})
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// verifySecondFactor checks either a TOTP code or a recovery code. Both are
+// consumed on success: a TOTP step cannot be reused and a recovery code is
+// marked used.
+func verifySecondFactor(tx *gorm.DB, userID uuid.UUID, code, recoveryCode string) (usedRecovery bool, ok bool, err error) {
+	if recoveryCode != "" {
+		result := tx.Model(&models.MFARecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, utils.HashToken(normalizeRecoveryCode(recoveryCode))).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return false, false, result.Error
+		}
+		return true, result.RowsAffected > 0, nil
+	}
+
+	var credential models.TOTPCredential
+	if err := tx.Where("user_id = ? AND confirmed_at IS NOT NULL", userID).First(&credential).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+
+	step, valid := utils.ValidateTOTP(credential.Secret, code, time.Now(), totpSkew)
+	if !valid {
+		return false, false, nil
+	}
+
+	result := tx.Model(&models.TOTPCredential{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, false, result.Error
+	}
+	return false, result.RowsAffected > 0, nil
+}
+
+// VerifyMFA completes a login started with a password by checking the second
+// factor and issuing the full token pair.
+func VerifyMFA(c *gin.Context) {
+	var req struct {
+		MFAToken     string `json:"mfa_token" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+		DeviceLabel  string `json:"device_label"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Code == "" && req.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code or recovery_code is required"})
+		return
+	}
+
+	cfg := config.LoadConfig()
+	claims, err := utils.ValidateToken(req.MFAToken, keys.Default)
+	if err != nil || claims.TokenType != utils.TokenTypeMFAPending {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	// Checked before the code so a replayed token cannot burn recovery codes
+	var pending models.UserToken
+	if err := database.DB.Where("token_hash = ? AND purpose = ? AND used_at IS NULL", utils.HashToken(claims.ID), models.TokenPurposeMFAPending).First(&pending).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	throttleKey := "mfa:" + claims.UserID.String()
+	if wait, err := throttle.Accounts.Check(throttleKey); err != nil {
+		log.Printf("Failed to check MFA throttle: %v", err)
+	} else if wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", claims.UserID).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	usedRecovery, ok, err := verifySecondFactor(database.DB, user.ID, req.Code, req.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !ok {
+		recordSecurityEvent(c, models.SecurityEventMFAFailed, &user.ID, user.Email, "")
+		if _, err := throttle.Accounts.Fail(throttleKey); err != nil {
+			log.Printf("Failed to record MFA failure: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	if err := throttle.Accounts.Succeed(throttleKey); err != nil {
+		log.Printf("Failed to reset MFA throttle: %v", err)
+	}
+	if usedRecovery {
+		recordSecurityEvent(c, models.SecurityEventRecoveryUsed, &user.ID, user.Email, "")
+	}
+
+	if _, err := consumeUserToken(database.DB, claims.ID, models.TokenPurposeMFAPending); err != nil {
+		if errors.Is(err, errInvalidUserToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+
+	response, err := startSession(database.DB, c, cfg, &user, req.DeviceLabel)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// SetupTOTP starts enrollment by generating a secret. Two-factor stays off
+// until ConfirmTOTP receives a valid code for it.
+func SetupTOTP(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.MFAEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	// Replace any earlier, unconfirmed enrollment
+	credential := models.TOTPCredential{UserID: user.ID, Secret: secret}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.TOTPCredential{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&credential).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+
+	cfg := config.LoadConfig()
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": utils.TOTPURI(cfg.App.Name, user.Email, secret),
+	})
+}
+
+func ConfirmTOTP(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var credential models.TOTPCredential
+	if err := database.DB.Where("user_id = ? AND confirmed_at IS NULL", userID).First(&credential).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No pending enrollment, start setup first"})
+		return
+	}
+
+	step, valid := utils.ValidateTOTP(credential.Secret, req.Code, time.Now(), totpSkew)
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&credential).Updates(map[string]interface{}{
+			"confirmed_at":   now,
+			"last_used_step": step,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("mfa_enabled", true).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = generateRecoveryCodes(tx, credential.UserID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	recordSecurityEvent(c, models.SecurityEventMFAEnabled, &credential.UserID, "", "")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+func DisableTOTP(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Password     string `json:"password" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !user.MFAEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	_, ok, err := verifySecondFactor(database.DB, user.ID, req.Code, req.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !ok {
+		recordSecurityEvent(c, models.SecurityEventMFAFailed, &user.ID, user.Email, "disable")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.TOTPCredential{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&user).Update("mfa_enabled", false).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	recordSecurityEvent(c, models.SecurityEventMFADisabled, &user.ID, user.Email, "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes invalidates the remaining recovery codes and
+// returns a fresh set. Requires a current TOTP code.
+func RegenerateRecoveryCodes(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := userID.(uuid.UUID)
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		_, ok, err := verifySecondFactor(tx, id, req.Code, "")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errInvalidMFACode
+		}
+
+		codes, err = generateRecoveryCodes(tx, id)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, errInvalidMFACode) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
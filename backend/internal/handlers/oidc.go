@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"english-learning-app/internal/config"
+	"english-learning-app/internal/database"
+	"english-learning-app/internal/models"
+	"english-learning-app/internal/oidc"
+	"english-learning-app/pkg/utils"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const oauthStateTTL = 10 * time.Minute
+
+var (
+	errInvalidOAuthState     = errors.New("invalid or expired state")
+	errIdentityEmailConflict = errors.New("an account with this email already exists")
+	errIdentityMissingEmail  = errors.New("identity provider did not return an email")
+	errIdentityAlreadyLinked = errors.New("identity is linked to another account")
+)
+
+func GetOIDCProviders(c *gin.Context) {
+	providers := []gin.H{}
+	for _, p := range oidc.List() {
+		providers = append(providers, gin.H{"name": p.Name, "display_name": p.DisplayName})
+	}
+
+	c.JSON(http.StatusOK, providers)
+}
+
+// StartOIDCLogin returns the provider URL the browser should be sent to in
+// order to sign in.
+func StartOIDCLogin(c *gin.Context) {
+	beginAuthorization(c, nil)
+}
+
+// StartOIDCLink is like StartOIDCLogin but links the external identity to the
+// signed-in account when the flow completes.
+func StartOIDCLink(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id := userID.(uuid.UUID)
+	beginAuthorization(c, &id)
+}
+
+func beginAuthorization(c *gin.Context, linkUserID *uuid.UUID) {
+	provider, err := oidc.Get(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Identity provider not found"})
+		return
+	}
+
+	state, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign in"})
+		return
+	}
+	nonce, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign in"})
+		return
+	}
+	verifier, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign in"})
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("OIDC provider %s unavailable: %v", provider.Name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+
+	record := models.OAuthState{
+		StateHash:    utils.HashToken(state),
+		Provider:     provider.Name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(oauthStateTTL),
+	}
+	if err := database.DB.Create(&record).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign in"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"authorization_url": authURL})
+}
+
+// OIDCCallback finishes the flow. The frontend page registered as redirect URL
+// posts the code and state it received from the provider.
+func OIDCCallback(c *gin.Context) {
+	var req struct {
+		Code        string `json:"code" binding:"required"`
+		State       string `json:"state" binding:"required"`
+		DeviceLabel string `json:"device_label"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	provider, err := oidc.Get(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Identity provider not found"})
+		return
+	}
+
+	state, err := consumeOAuthState(provider.Name, req.State)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired sign in request"})
+		return
+	}
+
+	token, err := provider.Exchange(c.Request.Context(), req.Code, state.CodeVerifier)
+	if err != nil {
+		log.Printf("OIDC code exchange with %s failed: %v", provider.Name, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to sign in with identity provider"})
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(c.Request.Context(), token.IDToken, state.Nonce)
+	if err != nil {
+		log.Printf("OIDC id_token from %s rejected: %v", provider.Name, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to sign in with identity provider"})
+		return
+	}
+
+	if state.LinkUserID != nil {
+		identity, err := linkIdentity(database.DB, *state.LinkUserID, provider.Name, claims)
+		if err != nil {
+			if errors.Is(err, errIdentityAlreadyLinked) {
+				c.JSON(http.StatusConflict, gin.H{"error": "This identity is already linked to another account"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link identity"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Identity linked successfully", "identity": identity})
+		return
+	}
+
+	var user models.User
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		return resolveOIDCUser(tx, provider.Name, claims, &user)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errIdentityEmailConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": "An account with this email already exists, sign in and link the provider from your profile"})
+		case errors.Is(err, errIdentityMissingEmail):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The identity provider did not share an email address"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+		}
+		return
+	}
+
+	cfg := config.LoadConfig()
+	if user.MFAEnabled {
+		respondMFAChallenge(c, cfg, &user)
+		return
+	}
+
+	response, err := startSession(database.DB, c, cfg, &user, req.DeviceLabel)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// consumeOAuthState deletes and returns the pending authorization request so a
+// state value can only be redeemed once.
+func consumeOAuthState(provider, state string) (*models.OAuthState, error) {
+	var record models.OAuthState
+	if err := database.DB.Where("state_hash = ? AND provider = ?", utils.HashToken(state), provider).First(&record).Error; err != nil {
+		return nil, errInvalidOAuthState
+	}
+
+	result := database.DB.Where("id = ?", record.ID).Delete(&models.OAuthState{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 || time.Now().After(record.ExpiresAt) {
+		return nil, errInvalidOAuthState
+	}
+
+	// Opportunistically clean up abandoned requests
+	database.DB.Where("expires_at < ?", time.Now()).Delete(&models.OAuthState{})
+
+	return &record, nil
+}
+
+// resolveOIDCUser finds the user behind an external identity. Unknown
+// identities are linked to an existing account with the same verified email,
+// or get a new account.
+func resolveOIDCUser(tx *gorm.DB, provider string, claims *oidc.IDTokenClaims, user *models.User) error {
+	var identity models.UserIdentity
+	err := tx.Where("provider = ? AND subject = ?", provider, claims.Subject).First(&identity).Error
+	if err == nil {
+		return tx.Where("id = ?", identity.UserID).First(user).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	email := normalizeEmail(claims.Email)
+	if email == "" {
+		return errIdentityMissingEmail
+	}
+
+	err = tx.Where("LOWER(email) = ?", email).First(user).Error
+	switch {
+	case err == nil:
+		// Only trust the provider's word that this is the same person if it
+		// verified the address
+		if !claims.EmailVerified {
+			return errIdentityEmailConflict
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := createOIDCUser(tx, email, claims, user); err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	identity = models.UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    email,
+	}
+	return tx.Create(&identity).Error
+}
+
+func createOIDCUser(tx *gorm.DB, email string, claims *oidc.IDTokenClaims, user *models.User) error {
+	// Accounts created through a provider get an unusable random password;
+	// the owner can set a real one with the password reset flow
+	randomPassword, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(randomPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	name := strings.TrimSpace(claims.Name)
+	if name == "" {
+		name = strings.SplitN(email, "@", 2)[0]
+	}
+
+	*user = models.User{
+		ID:       uuid.New(),
+		Name:     name,
+		Email:    email,
+		Password: string(hashedPassword),
+		Level:    "A0",
+		Points:   0,
+	}
+	if claims.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	return tx.Create(user).Error
+}
+
+func linkIdentity(db *gorm.DB, userID uuid.UUID, provider string, claims *oidc.IDTokenClaims) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := db.Where("provider = ? AND subject = ?", provider, claims.Subject).First(&identity).Error
+	if err == nil {
+		if identity.UserID != userID {
+			return nil, errIdentityAlreadyLinked
+		}
+		return &identity, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	identity = models.UserIdentity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    normalizeEmail(claims.Email),
+	}
+	if err := db.Create(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func GetIdentities(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var identities []models.UserIdentity
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch identities"})
+		return
+	}
+
+	c.JSON(http.StatusOK, identities)
+}
+
+func UnlinkIdentity(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	result := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).Delete(&models.UserIdentity{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink identity"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Identity not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Identity unlinked successfully"})
+}
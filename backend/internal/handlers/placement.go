@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"encoding/json"
+	"english-learning-app/internal/config"
+	"english-learning-app/internal/database"
+	"english-learning-app/internal/grading"
+	"english-learning-app/internal/models"
+	"english-learning-app/internal/placement"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PlacementQuestion is an exercise as shown during a placement test, without
+// its answer or anything revealing its level.
+type PlacementQuestion struct {
+	ExerciseID uuid.UUID              `json:"exercise_id"`
+	Type       string                 `json:"type"`
+	Question   string                 `json:"question"`
+	Options    []string               `json:"options"`
+	Prompt     *models.ExercisePrompt `json:"prompt,omitempty"`
+}
+
+type PlacementResponse struct {
+	ID          uuid.UUID          `json:"id"`
+	Status      string             `json:"status"`
+	Answered    int64              `json:"answered"`
+	ResultLevel *string            `json:"result_level"`
+	Question    *PlacementQuestion `json:"question"`
+}
+
+func placementResponse(test *models.PlacementTest) (*PlacementResponse, error) {
+	response := &PlacementResponse{ID: test.ID, Status: test.Status, ResultLevel: test.ResultLevel}
+	if err := database.DB.Model(&models.PlacementAnswer{}).Where("test_id = ?", test.ID).Count(&response.Answered).Error; err != nil {
+		return nil, err
+	}
+
+	if test.ExerciseID != nil {
+		var exercise models.Exercise
+		if err := database.DB.Where("id = ?", *test.ExerciseID).First(&exercise).Error; err != nil {
+			return nil, err
+		}
+		response.Question = &PlacementQuestion{
+			ExerciseID: exercise.ID,
+			Type:       exercise.Type,
+			Question:   exercise.Question,
+			Options:    exercise.Options,
+			Prompt:     grading.PromptFor(&exercise),
+		}
+	}
+	return response, nil
+}
+
+// placementPending reports whether the exercise is the current question of
+// the user's placement test. Answering it anywhere else would tell whether
+// an answer is right before it counts.
+func placementPending(db *gorm.DB, userID, exerciseID interface{}) (bool, error) {
+	var count int64
+	err := db.Model(&models.PlacementTest{}).
+		Where("user_id = ? AND status = ? AND exercise_id = ?", userID, models.PlacementInProgress, exerciseID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GetPlacementTest returns the user's placement test, if they started one.
+func GetPlacementTest(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var test models.PlacementTest
+	if err := database.DB.Where("user_id = ?", userID).Order("started_at DESC").First(&test).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No placement test found"})
+		return
+	}
+
+	response, err := placementResponse(&test)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch placement test"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// StartPlacementTest starts a placement test, or resumes the one in
+// progress, and returns its current question.
+func StartPlacementTest(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	test, err := placement.Start(database.DB, userID.(uuid.UUID))
+	if errors.Is(err, placement.ErrAlreadyPlaced) {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already taken the placement test"})
+		return
+	}
+	if errors.Is(err, placement.ErrNoQuestions) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "The placement test is not available yet"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start placement test"})
+		return
+	}
+
+	response, err := placementResponse(test)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start placement test"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// AnswerPlacementQuestion grades the answer to the current question and
+// returns the next one, or the placed level once the test has finished.
+func AnswerPlacementQuestion(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	testID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Placement test not found"})
+		return
+	}
+
+	var req struct {
+		ExerciseID uuid.UUID       `json:"exercise_id" binding:"required"`
+		Answer     json.RawMessage `json:"answer" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var exercise models.Exercise
+	if err := database.DB.Where("id = ?", req.ExerciseID).First(&exercise).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exercise not found"})
+		return
+	}
+
+	graded, answer, err := gradeAnswer(&exercise, req.Answer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Answer is not in the format of a " + exercise.Type + " exercise"})
+		return
+	}
+
+	cfg := config.LoadConfig()
+	result, err := placement.Answer(database.DB, testID, userID.(uuid.UUID), exercise.ID, answer, graded.IsCorrect, placement.PolicyFromConfig(cfg))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Placement test not found"})
+		return
+	case errors.Is(err, placement.ErrNotInProgress):
+		c.JSON(http.StatusConflict, gin.H{"error": "Placement test is already finished"})
+		return
+	case errors.Is(err, placement.ErrNotPending):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Answer the current question of the test"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save answer"})
+		return
+	}
+
+	response, err := placementResponse(result.Test)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save answer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"is_correct": result.IsCorrect,
+		"finished":   result.Finished,
+		"test":       response,
+		"level_up":   result.LevelUp,
+		"tested_out": result.TestedOut,
+	})
+}
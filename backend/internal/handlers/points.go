@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"english-learning-app/internal/database"
+	"english-learning-app/internal/models"
+	"english-learning-app/internal/points"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetPointsLedger returns a user's ledger entries, newest first, together
+// with the cached balance and the ledger sum so drift is visible.
+func GetPointsLedger(c *gin.Context) {
+	var user models.User
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	query := database.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Limit(limit).Offset(offset)
+	if sourceType := c.Query("source_type"); sourceType != "" {
+		query = query.Where("source_type = ?", sourceType)
+	}
+
+	var entries []models.PointsEntry
+	if err := query.Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch points ledger"})
+		return
+	}
+
+	balance, err := points.Balance(database.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch points ledger"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":        user.ID,
+		"cached_balance": user.Points,
+		"ledger_balance": balance,
+		"entries":        entries,
+	})
+}
+
+// AdjustPoints appends a manual correction to a user's ledger. Clients can
+// pass an idempotency key to make retries safe.
+func AdjustPoints(c *gin.Context) {
+	adminID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Delta          int    `json:"delta" binding:"required"`
+		Reason         string `json:"reason" binding:"required"`
+		IdempotencyKey string `json:"idempotency_key"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	key := "admin_adjustment:" + uuid.New().String()
+	if req.IdempotencyKey != "" {
+		key = "admin_adjustment:" + user.ID.String() + ":" + req.IdempotencyKey
+	}
+
+	createdBy := adminID.(uuid.UUID)
+	entry := models.PointsEntry{
+		UserID:         user.ID,
+		SourceType:     models.PointsSourceAdjustment,
+		Delta:          req.Delta,
+		IdempotencyKey: key,
+		Reason:         req.Reason,
+		CreatedBy:      &createdBy,
+	}
+
+	applied, err := points.Award(database.DB, &entry)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to adjust points"})
+		return
+	}
+	if !applied {
+		if err := database.DB.Where("idempotency_key = ?", key).First(&entry).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to adjust points"})
+			return
+		}
+	}
+
+	if err := database.DB.Where("id = ?", user.ID).First(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	status := http.StatusCreated
+	if !applied {
+		status = http.StatusOK
+	}
+	c.JSON(status, gin.H{"entry": entry, "balance": user.Points})
+}
+
+// RecomputePoints resets the cached balance to the ledger sum.
+func RecomputePoints(c *gin.Context) {
+	var user models.User
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	balance, err := points.Recompute(database.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recompute points"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":          user.ID,
+		"previous_balance": user.Points,
+		"balance":          balance,
+	})
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"english-learning-app/internal/config"
+	"english-learning-app/internal/grading"
+	"english-learning-app/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ExerciseResult struct {
+	ExerciseID uuid.UUID `json:"exercise_id"`
+	Question   string    `json:"question"`
+	Points     int       `json:"points"`
+	BestScore  int       `json:"best_score"`
+	Attempted  bool      `json:"attempted"`
+	IsCorrect  bool      `json:"is_correct"`
+}
+
+type TopicResult struct {
+	TopicID       uuid.UUID        `json:"topic_id"`
+	Score         int              `json:"score"`
+	MaxScore      int              `json:"max_score"`
+	Percent       int              `json:"percent"`
+	PassThreshold int              `json:"pass_threshold"`
+	Passed        bool             `json:"passed"`
+	PreviousBest  int              `json:"previous_best"`
+	PointsAwarded int              `json:"points_awarded"`
+	Exercises     []ExerciseResult `json:"exercises"`
+
+	AchievementsUnlocked []models.Achievement      `json:"achievements_unlocked"`
+	LevelUps             []models.UserLevelHistory `json:"level_ups"`
+}
+
+// gradeAnswer grades an answer to exercise in the format of its type and
+// returns the answer as stored on the attempt.
+func gradeAnswer(exercise *models.Exercise, answer json.RawMessage) (grading.Result, string, error) {
+	return grading.GradeExercise(exercise, answer, grading.OptionsFromConfig(config.LoadConfig()))
+}
+
+// hideAnswer prepares exercise to be shown to learners: answers are graded
+// on the server, and structured exercises only show their shuffled prompt.
+func hideAnswer(exercise *models.Exercise) {
+	exercise.Prompt = grading.PromptFor(exercise)
+	exercise.CorrectAnswer = ""
+	exercise.AcceptedAnswers = nil
+	exercise.Payload = nil
+}
+
+// scoreTopic computes the user's topic score from their best attempt at each
+// active exercise of the topic. Scores sent by the client are never used.
+func scoreTopic(tx *gorm.DB, userID, topicID uuid.UUID, passThreshold int) (*TopicResult, error) {
+	var exercises []models.Exercise
+	if err := tx.Where("topic_id = ? AND is_active = ?", topicID, true).Order(`"order"`).Find(&exercises).Error; err != nil {
+		return nil, err
+	}
+
+	var best []struct {
+		ExerciseID uuid.UUID
+		BestScore  int
+		AnyCorrect bool
+	}
+	if err := tx.Model(&models.ExerciseAttempt{}).
+		Select("exercise_id, MAX(score) AS best_score, BOOL_OR(is_correct) AS any_correct").
+		Where("user_id = ? AND exercise_id IN (?)", userID, tx.Model(&models.Exercise{}).Select("id").Where("topic_id = ?", topicID)).
+		Group("exercise_id").
+		Scan(&best).Error; err != nil {
+		return nil, err
+	}
+
+	bestByExercise := make(map[uuid.UUID]int, len(best))
+	correctByExercise := make(map[uuid.UUID]bool, len(best))
+	for _, row := range best {
+		bestByExercise[row.ExerciseID] = row.BestScore
+		correctByExercise[row.ExerciseID] = row.AnyCorrect
+	}
+
+	result := &TopicResult{
+		TopicID:       topicID,
+		PassThreshold: passThreshold,
+		Exercises:     make([]ExerciseResult, 0, len(exercises)),
+		LevelUps:      []models.UserLevelHistory{},
+	}
+	for _, exercise := range exercises {
+		bestScore, attempted := bestByExercise[exercise.ID]
+		// Attempts recorded before an exercise's points were lowered must
+		// not count for more than the exercise is worth now
+		if bestScore > exercise.Points {
+			bestScore = exercise.Points
+		}
+
+		result.Score += bestScore
+		result.MaxScore += exercise.Points
+		result.Exercises = append(result.Exercises, ExerciseResult{
+			ExerciseID: exercise.ID,
+			Question:   exercise.Question,
+			Points:     exercise.Points,
+			BestScore:  bestScore,
+			Attempted:  attempted,
+			IsCorrect:  correctByExercise[exercise.ID],
+		})
+	}
+
+	if result.MaxScore > 0 {
+		result.Percent = result.Score * 100 / result.MaxScore
+	} else {
+		result.Percent = 100
+	}
+	result.Passed = result.Percent >= passThreshold
+
+	return result, nil
+}
@@ -0,0 +1,493 @@
+package handlers
+
+import (
+	"encoding/json"
+	"english-learning-app/internal/achievements"
+	"english-learning-app/internal/config"
+	"english-learning-app/internal/database"
+	"english-learning-app/internal/grading"
+	"english-learning-app/internal/models"
+	"english-learning-app/internal/quiz"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// QuizQuestion is the current question of a quiz session, without its
+// answer.
+type QuizQuestion struct {
+	Position   int                    `json:"position"`
+	ExerciseID uuid.UUID              `json:"exercise_id"`
+	Type       string                 `json:"type"`
+	Question   string                 `json:"question"`
+	Options    []string               `json:"options"`
+	Prompt     *models.ExercisePrompt `json:"prompt,omitempty"`
+	Points     int                    `json:"points"`
+}
+
+type QuizResponse struct {
+	ID               uuid.UUID     `json:"id"`
+	TopicID          uuid.UUID     `json:"topic_id"`
+	Status           string        `json:"status"`
+	StartedAt        time.Time     `json:"started_at"`
+	ExpiresAt        *time.Time    `json:"expires_at"`
+	RemainingSeconds *int          `json:"remaining_seconds"`
+	Answered         int           `json:"answered"`
+	Total            int           `json:"total"`
+	Score            int           `json:"score"`
+	MaxScore         int           `json:"max_score"`
+	Question         *QuizQuestion `json:"question"`
+	Report           *QuizReport   `json:"report,omitempty"`
+}
+
+type QuizReportItem struct {
+	Position    int       `json:"position"`
+	ExerciseID  uuid.UUID `json:"exercise_id"`
+	Question    string    `json:"question"`
+	Points      int       `json:"points"`
+	Answered    bool      `json:"answered"`
+	Answer      string    `json:"answer,omitempty"`
+	IsCorrect   bool      `json:"is_correct"`
+	Score       int       `json:"score"`
+	WrongBlanks []int     `json:"wrong_blanks,omitempty"`
+	Explanation string    `json:"explanation,omitempty"`
+}
+
+// QuizReport summarizes a finished session. Correct answers are not
+// revealed, as the same exercises count towards topic completion.
+type QuizReport struct {
+	Score           int              `json:"score"`
+	MaxScore        int              `json:"max_score"`
+	Percent         int              `json:"percent"`
+	PassThreshold   int              `json:"pass_threshold"`
+	Passed          bool             `json:"passed"`
+	Answered        int              `json:"answered"`
+	Correct         int              `json:"correct"`
+	Total           int              `json:"total"`
+	DurationSeconds int              `json:"duration_seconds"`
+	Items           []QuizReportItem `json:"items"`
+}
+
+func quizResponse(session *models.QuizSession, now time.Time) (*QuizResponse, error) {
+	response := &QuizResponse{
+		ID:        session.ID,
+		TopicID:   session.TopicID,
+		Status:    session.Status,
+		StartedAt: session.StartedAt,
+		ExpiresAt: session.ExpiresAt,
+		Total:     len(session.Items),
+		Score:     session.Score,
+		MaxScore:  session.MaxScore,
+	}
+	for _, item := range session.Items {
+		if item.AttemptID != nil {
+			response.Answered++
+		}
+	}
+
+	if session.Status != models.QuizInProgress {
+		report, err := quizReport(session)
+		if err != nil {
+			return nil, err
+		}
+		response.Report = report
+		return response, nil
+	}
+
+	if session.ExpiresAt != nil {
+		remaining := int(session.ExpiresAt.Sub(now) / time.Second)
+		if remaining < 0 {
+			remaining = 0
+		}
+		response.RemainingSeconds = &remaining
+	}
+
+	if item := quiz.Current(session); item != nil {
+		var exercise models.Exercise
+		if err := database.DB.Where("id = ?", item.ExerciseID).First(&exercise).Error; err != nil {
+			return nil, err
+		}
+		response.Question = &QuizQuestion{
+			Position:   item.Position,
+			ExerciseID: exercise.ID,
+			Type:       exercise.Type,
+			Question:   exercise.Question,
+			Options:    exercise.Options,
+			Prompt:     grading.PromptFor(&exercise),
+			Points:     item.Points,
+		}
+	}
+	return response, nil
+}
+
+func quizReport(session *models.QuizSession) (*QuizReport, error) {
+	exerciseIDs := make([]uuid.UUID, 0, len(session.Items))
+	attemptIDs := make([]uuid.UUID, 0, len(session.Items))
+	for _, item := range session.Items {
+		exerciseIDs = append(exerciseIDs, item.ExerciseID)
+		if item.AttemptID != nil {
+			attemptIDs = append(attemptIDs, *item.AttemptID)
+		}
+	}
+
+	var exercises []models.Exercise
+	if err := database.DB.Where("id IN ?", exerciseIDs).Find(&exercises).Error; err != nil {
+		return nil, err
+	}
+	exerciseByID := make(map[uuid.UUID]models.Exercise, len(exercises))
+	for _, exercise := range exercises {
+		exerciseByID[exercise.ID] = exercise
+	}
+
+	var attempts []models.ExerciseAttempt
+	if len(attemptIDs) > 0 {
+		if err := database.DB.Where("id IN ?", attemptIDs).Find(&attempts).Error; err != nil {
+			return nil, err
+		}
+	}
+	attemptByID := make(map[uuid.UUID]models.ExerciseAttempt, len(attempts))
+	for _, attempt := range attempts {
+		attemptByID[attempt.ID] = attempt
+	}
+
+	cfg := config.LoadConfig()
+	report := &QuizReport{
+		Score:         session.Score,
+		MaxScore:      session.MaxScore,
+		PassThreshold: cfg.Progress.PassThreshold,
+		Total:         len(session.Items),
+		Items:         make([]QuizReportItem, 0, len(session.Items)),
+	}
+	if report.MaxScore > 0 {
+		report.Percent = report.Score * 100 / report.MaxScore
+	} else {
+		report.Percent = 100
+	}
+	report.Passed = report.Percent >= report.PassThreshold
+	if session.FinishedAt != nil {
+		report.DurationSeconds = int(session.FinishedAt.Sub(session.StartedAt) / time.Second)
+	}
+
+	for _, item := range session.Items {
+		entry := QuizReportItem{
+			Position:   item.Position,
+			ExerciseID: item.ExerciseID,
+			Question:   exerciseByID[item.ExerciseID].Question,
+			Points:     item.Points,
+		}
+		if item.AttemptID != nil {
+			attempt := attemptByID[*item.AttemptID]
+			entry.Answered, entry.Answer, entry.IsCorrect = true, attempt.Answer, attempt.IsCorrect
+			entry.Score, entry.WrongBlanks = attempt.Score, attempt.WrongBlanks
+			if entry.Score > item.Points {
+				entry.Score = item.Points
+			}
+			entry.Explanation = exerciseByID[item.ExerciseID].Explanation
+			report.Answered++
+			if attempt.IsCorrect {
+				report.Correct++
+			}
+		}
+		report.Items = append(report.Items, entry)
+	}
+	return report, nil
+}
+
+// quizPending reports whether the exercise is an unanswered question of one
+// of the user's quizzes in progress other than except, which may be
+// uuid.Nil. Answering it anywhere else would tell whether an answer is right
+// before it counts.
+func quizPending(db *gorm.DB, userID, exerciseID interface{}, except uuid.UUID) (bool, error) {
+	var count int64
+	err := db.Model(&models.QuizItem{}).
+		Joins("JOIN quiz_sessions s ON s.id = quiz_items.session_id").
+		Where("s.user_id = ? AND s.status = ? AND s.id <> ? AND quiz_items.exercise_id = ? AND quiz_items.attempt_id IS NULL",
+			userID, models.QuizInProgress, except, exerciseID).
+		Where("s.expires_at IS NULL OR s.expires_at > NOW()").
+		Count(&count).Error
+	return count > 0, err
+}
+
+// StartQuiz starts a quiz session over a topic's exercises, optionally
+// shuffled and with a time limit in seconds.
+func StartQuiz(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		TopicID          uuid.UUID `json:"topic_id" binding:"required"`
+		TimeLimitSeconds int       `json:"time_limit_seconds"`
+		Shuffle          bool      `json:"shuffle"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := config.LoadConfig()
+	if req.TimeLimitSeconds < 0 || req.TimeLimitSeconds > cfg.Quiz.MaxTimeLimitSeconds {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Time limit is out of range"})
+		return
+	}
+
+	var topic models.Topic
+	if err := database.DB.Where("id = ? AND is_active = ?", req.TopicID, true).First(&topic).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Topic not found"})
+		return
+	}
+
+	now := time.Now()
+	session, err := quiz.Start(database.DB, userID.(uuid.UUID), topic.ID, time.Duration(req.TimeLimitSeconds)*time.Second, req.Shuffle, now)
+	if errors.Is(err, quiz.ErrNoExercises) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Topic has no exercises"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start quiz"})
+		return
+	}
+
+	response, err := quizResponse(session, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start quiz"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetQuiz returns a session with its current question, or its report once
+// finished.
+func GetQuiz(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return
+	}
+
+	cfg := config.LoadConfig()
+	now := time.Now()
+	session, err := quiz.Load(database.DB, sessionID, userID.(uuid.UUID), now, time.Duration(cfg.Quiz.GraceSeconds)*time.Second)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quiz"})
+		return
+	}
+
+	response, err := quizResponse(session, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quiz"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetQuizQuestion returns the question to answer next.
+func GetQuizQuestion(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return
+	}
+
+	cfg := config.LoadConfig()
+	now := time.Now()
+	session, err := quiz.Load(database.DB, sessionID, userID.(uuid.UUID), now, time.Duration(cfg.Quiz.GraceSeconds)*time.Second)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch question"})
+		return
+	}
+	if session.Status != models.QuizInProgress {
+		c.JSON(http.StatusConflict, gin.H{"error": "Quiz is no longer in progress", "status": session.Status})
+		return
+	}
+
+	response, err := quizResponse(session, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch question"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"question":          response.Question,
+		"remaining_seconds": response.RemainingSeconds,
+	})
+}
+
+// AnswerQuiz grades the answer to the current question and records the
+// attempt against the session.
+func AnswerQuiz(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return
+	}
+
+	var req struct {
+		ExerciseID uuid.UUID       `json:"exercise_id" binding:"required"`
+		Answer     json.RawMessage `json:"answer" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var exercise models.Exercise
+	if err := database.DB.Where("id = ?", req.ExerciseID).First(&exercise).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exercise not found"})
+		return
+	}
+
+	pending, err := placementPending(database.DB, userID, exercise.ID)
+	if err == nil && !pending {
+		pending, err = quizPending(database.DB, userID, exercise.ID, sessionID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check exercise"})
+		return
+	}
+	if pending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Answer this exercise in the test you have in progress"})
+		return
+	}
+
+	result, answer, err := gradeAnswer(&exercise, req.Answer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Answer is not in the format of a " + exercise.Type + " exercise"})
+		return
+	}
+	attempt := models.ExerciseAttempt{
+		UserID:      userID.(uuid.UUID),
+		ExerciseID:  exercise.ID,
+		Answer:      answer,
+		IsCorrect:   result.IsCorrect,
+		Score:       result.Score,
+		WrongBlanks: result.WrongBlanks(),
+		AttemptedAt: time.Now(),
+	}
+
+	cfg := config.LoadConfig()
+	session, err := quiz.Answer(database.DB, sessionID, &attempt, time.Duration(cfg.Quiz.GraceSeconds)*time.Second)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return
+	case errors.Is(err, quiz.ErrExpired):
+		response, err := quizResponse(session, attempt.AttemptedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save answer"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "Time limit exceeded", "quiz": response})
+		return
+	case errors.Is(err, quiz.ErrNotActive):
+		c.JSON(http.StatusConflict, gin.H{"error": "Quiz is no longer in progress"})
+		return
+	case errors.Is(err, quiz.ErrNotCurrent):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Answer the current question of the quiz"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save answer"})
+		return
+	}
+
+	joinWeeklyLeague(attempt.UserID)
+
+	unlocked, err := achievements.Evaluate(database.DB, attempt.UserID, achievements.EventExerciseAttempted)
+	if err != nil {
+		log.Printf("Failed to evaluate achievements for user %s: %v", attempt.UserID, err)
+	}
+
+	daily, streakUnlocked := recordDailyActivity(attempt.UserID)
+	unlocked = append(unlocked, streakUnlocked...)
+
+	response, err := quizResponse(session, attempt.AttemptedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quiz"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"is_correct":            result.IsCorrect,
+		"score":                 result.Score,
+		"verdict":               result.Verdict,
+		"corrected":             result.Corrected,
+		"blanks":                result.Blanks,
+		"explanation":           exercise.Explanation,
+		"quiz":                  response,
+		"achievements_unlocked": unlocked,
+		"daily":                 daily,
+	})
+}
+
+// FinishQuiz ends a session, scoring unanswered questions as zero, and
+// returns the report.
+func FinishQuiz(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return
+	}
+
+	cfg := config.LoadConfig()
+	now := time.Now()
+	session, err := quiz.Finish(database.DB, sessionID, userID.(uuid.UUID), now, time.Duration(cfg.Quiz.GraceSeconds)*time.Second)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to finish quiz"})
+		return
+	}
+
+	response, err := quizResponse(session, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to finish quiz"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
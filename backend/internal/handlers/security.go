@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"english-learning-app/internal/database"
+	"english-learning-app/internal/models"
+	"english-learning-app/internal/throttle"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// recordSecurityEvent stores an audit event. Failures are logged rather than
+// returned so auditing never breaks the request being audited.
+func recordSecurityEvent(c *gin.Context, eventType string, userID *uuid.UUID, email, details string) {
+	event := models.SecurityEvent{
+		UserID:    userID,
+		Type:      eventType,
+		Email:     email,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Details:   details,
+	}
+	if err := database.DB.Create(&event).Error; err != nil {
+		log.Printf("Failed to record security event %s: %v", eventType, err)
+	}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginThrottled responds with 429 and reports true if either the account or
+// the client IP has to wait before another login attempt.
+func loginThrottled(c *gin.Context, email string) bool {
+	accountWait, err := throttle.Accounts.Check(normalizeEmail(email))
+	if err != nil {
+		log.Printf("Failed to check account throttle: %v", err)
+	}
+	ipWait, err := throttle.ClientIPs.Check(c.ClientIP())
+	if err != nil {
+		log.Printf("Failed to check IP throttle: %v", err)
+	}
+
+	wait := accountWait
+	if ipWait > wait {
+		wait = ipWait
+	}
+	if wait <= 0 {
+		return false
+	}
+
+	recordSecurityEvent(c, models.SecurityEventLoginThrottled, nil, email, "retry after "+wait.Round(time.Second).String())
+	respondTooManyAttempts(c, wait)
+	return true
+}
+
+func respondTooManyAttempts(c *gin.Context, wait time.Duration) {
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many attempts, please try again later",
+		"retry_after": retryAfter,
+	})
+}
+
+// recordLoginFailure counts a failed attempt against the account and the
+// client IP. userID is nil when the email does not belong to any account.
+func recordLoginFailure(c *gin.Context, email string, userID *uuid.UUID) {
+	recordSecurityEvent(c, models.SecurityEventLoginFailed, userID, email, "")
+
+	if locked, err := throttle.Accounts.Fail(normalizeEmail(email)); err != nil {
+		log.Printf("Failed to record account login failure: %v", err)
+	} else if locked {
+		recordSecurityEvent(c, models.SecurityEventAccountLocked, userID, email, "")
+	}
+
+	if locked, err := throttle.ClientIPs.Fail(c.ClientIP()); err != nil {
+		log.Printf("Failed to record IP login failure: %v", err)
+	} else if locked {
+		recordSecurityEvent(c, models.SecurityEventIPLocked, nil, email, "")
+	}
+}
+
+func recordLoginSuccess(email string) {
+	if err := throttle.Accounts.Succeed(normalizeEmail(email)); err != nil {
+		log.Printf("Failed to reset account throttle: %v", err)
+	}
+}
+
+func GetSecurityEvents(c *gin.Context) {
+	query := database.DB.Order("created_at DESC").Limit(100)
+
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if email := c.Query("email"); email != "" {
+		query = query.Where("LOWER(email) = ?", normalizeEmail(email))
+	}
+	if eventType := c.Query("type"); eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC 3339 timestamp"})
+			return
+		}
+		query = query.Where("created_at >= ?", t)
+	}
+
+	var events []models.SecurityEvent
+	if err := query.Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch security events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"english-learning-app/internal/database"
+	"english-learning-app/internal/models"
+	"english-learning-app/pkg/utils"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PublicUser is what other learners may see about a user.
+type PublicUser struct {
+	ID     uuid.UUID `json:"id"`
+	Name   string    `json:"name"`
+	Avatar *string   `json:"avatar"`
+	Level  string    `json:"level"`
+}
+
+type FriendEntry struct {
+	User       PublicUser `json:"user"`
+	Status     string     `json:"status"`
+	Incoming   bool       `json:"incoming"` // a pending request sent to the caller
+	CreatedAt  time.Time  `json:"created_at"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+}
+
+func publicUsers(ids []uuid.UUID) (map[uuid.UUID]PublicUser, error) {
+	var users []models.User
+	if len(ids) > 0 {
+		if err := database.DB.Where("id IN ?", ids).Find(&users).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	result := make(map[uuid.UUID]PublicUser, len(users))
+	for _, u := range users {
+		result[u.ID] = PublicUser{ID: u.ID, Name: u.Name, Avatar: u.Avatar, Level: u.Level}
+	}
+	return result, nil
+}
+
+func GetFriends(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	me := userID.(uuid.UUID)
+
+	var friendships []models.Friendship
+	if err := database.DB.Where("user_id = ? OR friend_id = ?", me, me).Order("created_at").Find(&friendships).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch friends"})
+		return
+	}
+
+	ids := make([]uuid.UUID, 0, len(friendships))
+	for _, f := range friendships {
+		if f.UserID == me {
+			ids = append(ids, f.FriendID)
+		} else {
+			ids = append(ids, f.UserID)
+		}
+	}
+	users, err := publicUsers(ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch friends"})
+		return
+	}
+
+	entries := make([]FriendEntry, 0, len(friendships))
+	for i, f := range friendships {
+		user, ok := users[ids[i]]
+		if !ok {
+			continue
+		}
+		entries = append(entries, FriendEntry{
+			User:       user,
+			Status:     f.Status,
+			Incoming:   f.Status == models.FriendshipPending && f.FriendID == me,
+			CreatedAt:  f.CreatedAt,
+			AcceptedAt: f.AcceptedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// AddFriend sends a friend request, or accepts the pending request the other
+// user already sent.
+func AddFriend(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	me := userID.(uuid.UUID)
+
+	friendID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if friendID == me {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot add yourself as a friend"})
+		return
+	}
+
+	var friend models.User
+	if err := database.DB.Where("id = ? AND is_guest = ?", friendID, false).First(&friend).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var status string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// A request in the other direction turns into a friendship
+		result := tx.Model(&models.Friendship{}).
+			Where("user_id = ? AND friend_id = ? AND status = ?", friendID, me, models.FriendshipPending).
+			Updates(map[string]interface{}{"status": models.FriendshipAccepted, "accepted_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			status = models.FriendshipAccepted
+			return nil
+		}
+
+		var existing models.Friendship
+		err := tx.Where("(user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)", me, friendID, friendID, me).First(&existing).Error
+		if err == nil {
+			status = existing.Status
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		status = models.FriendshipPending
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.Friendship{
+			UserID:   me,
+			FriendID: friendID,
+			Status:   models.FriendshipPending,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add friend"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user_id": friend.ID, "status": status})
+}
+
+// RemoveFriend ends a friendship, or cancels or declines a pending request.
+func RemoveFriend(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	me := userID.(uuid.UUID)
+
+	friendID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	result := database.DB.
+		Where("(user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)", me, friendID, friendID, me).
+		Delete(&models.Friendship{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove friend"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Friend not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Friend removed successfully"})
+}
+
+// GetClassrooms lists the classrooms the user owns or belongs to. Join codes
+// are only shown to owners.
+func GetClassrooms(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	me := userID.(uuid.UUID)
+
+	var classrooms []models.Classroom
+	if err := database.DB.
+		Where("owner_id = ? OR id IN (?)", me, database.DB.Model(&models.ClassroomMember{}).Select("classroom_id").Where("user_id = ?", me)).
+		Order("created_at").
+		Find(&classrooms).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch classrooms"})
+		return
+	}
+
+	for i := range classrooms {
+		if classrooms[i].OwnerID != me {
+			classrooms[i].JoinCode = ""
+		}
+	}
+
+	c.JSON(http.StatusOK, classrooms)
+}
+
+func CreateClassroom(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Name string `json:"name" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	code, err := utils.GenerateRandomToken(6)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create classroom"})
+		return
+	}
+
+	classroom := models.Classroom{
+		Name:     req.Name,
+		OwnerID:  userID.(uuid.UUID),
+		JoinCode: code,
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&classroom).Error; err != nil {
+			return err
+		}
+		// Owners appear on their classroom's leaderboard too
+		return tx.Create(&models.ClassroomMember{ClassroomID: classroom.ID, UserID: classroom.OwnerID, JoinedAt: time.Now()}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create classroom"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, classroom)
+}
+
+func JoinClassroom(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var classroom models.Classroom
+	if err := database.DB.Where("join_code = ?", req.Code).First(&classroom).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Classroom not found"})
+		return
+	}
+
+	member := models.ClassroomMember{ClassroomID: classroom.ID, UserID: userID.(uuid.UUID), JoinedAt: time.Now()}
+	if err := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join classroom"})
+		return
+	}
+
+	classroom.JoinCode = ""
+	c.JSON(http.StatusOK, classroom)
+}
+
+func LeaveClassroom(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var classroom models.Classroom
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&classroom).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Classroom not found"})
+		return
+	}
+	if classroom.OwnerID == userID.(uuid.UUID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Owners cannot leave their own classroom"})
+		return
+	}
+
+	result := database.DB.Where("classroom_id = ? AND user_id = ?", classroom.ID, userID).Delete(&models.ClassroomMember{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave classroom"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not a member of this classroom"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Left classroom successfully"})
+}
+
+// isClassroomMember reports whether the user belongs to the classroom.
+func isClassroomMember(classroomID, userID uuid.UUID) (bool, error) {
+	var count int64
+	err := database.DB.Model(&models.ClassroomMember{}).
+		Where("classroom_id = ? AND user_id = ?", classroomID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"english-learning-app/internal/config"
+	"english-learning-app/internal/database"
+	"english-learning-app/internal/keys"
+	"english-learning-app/internal/models"
+	"english-learning-app/pkg/utils"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	errInvalidRefreshToken = errors.New("invalid refresh token")
+	errRefreshTokenReused  = errors.New("refresh token reuse detected")
+	errInvalidUserToken    = errors.New("invalid or expired token")
+)
+
+// startSession records a new signed-in device for the user and issues its first
+// token pair. deviceLabel is optional; when empty one is derived from the
+// User-Agent header.
+func startSession(tx *gorm.DB, c *gin.Context, cfg *config.Config, user *models.User, deviceLabel string) (*AuthResponse, error) {
+	if deviceLabel == "" {
+		deviceLabel = deviceLabelFromUserAgent(c.Request.UserAgent())
+	}
+
+	session := models.Session{
+		UserID:      user.ID,
+		DeviceLabel: deviceLabel,
+		IPAddress:   c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+		LastSeenAt:  time.Now(),
+		ExpiresAt:   sessionExpiry(cfg, user),
+	}
+	if err := tx.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	response, _, err := issueTokens(tx, cfg, user, &session)
+	return response, err
+}
+
+// sessionExpiry is when a session started or refreshed now ends. Guest
+// sessions never outlive the guest account.
+func sessionExpiry(cfg *config.Config, user *models.User) time.Time {
+	expiresAt := time.Now().Add(time.Duration(cfg.JWT.RefreshTokenExpiry) * time.Hour)
+	if user.IsGuest && user.GuestExpiresAt != nil && user.GuestExpiresAt.Before(expiresAt) {
+		return *user.GuestExpiresAt
+	}
+	return expiresAt
+}
+
+// issueTokens signs a new access/refresh pair for the user within the given
+// session and stores the hash of the refresh token.
+func issueTokens(tx *gorm.DB, cfg *config.Config, user *models.User, session *models.Session) (*AuthResponse, *models.RefreshToken, error) {
+	roles, err := models.LoadUserRoles(tx, user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	accessToken, err := utils.GenerateToken(user.ID, session.ID, user.Email, roles, keys.Default, cfg.JWT.AccessTokenExpiry)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshToken, err := utils.GenerateRefreshToken(user.ID, session.ID, keys.Default, cfg.JWT.RefreshTokenExpiry)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	record := models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  session.ID,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: sessionExpiry(cfg, user),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return nil, nil, err
+	}
+
+	// Clear password from response
+	response := &AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		User:         *user,
+	}
+	response.User.Password = ""
+
+	return response, &record, nil
+}
+
+// rotateRefreshToken exchanges a valid refresh token for a new pair. The
+// presented token is revoked; presenting an already revoked token is treated
+// as theft and revokes its whole session.
+func rotateRefreshToken(c *gin.Context, cfg *config.Config, presented string) (*AuthResponse, error) {
+	claims, err := utils.ValidateToken(presented, keys.Default)
+	if err != nil || claims.TokenType != utils.TokenTypeRefresh {
+		return nil, errInvalidRefreshToken
+	}
+
+	var stored models.RefreshToken
+	if err := database.DB.Where("token_hash = ?", utils.HashToken(presented)).First(&stored).Error; err != nil {
+		return nil, errInvalidRefreshToken
+	}
+
+	if stored.RevokedAt != nil {
+		return nil, handleRefreshReuse(&stored)
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, errInvalidRefreshToken
+	}
+
+	var session models.Session
+	if err := database.DB.Where("id = ? AND revoked_at IS NULL", stored.FamilyID).First(&session).Error; err != nil {
+		return nil, errInvalidRefreshToken
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", stored.UserID).First(&user).Error; err != nil {
+		return nil, errInvalidRefreshToken
+	}
+
+	var response *AuthResponse
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Conditional update so two concurrent refreshes cannot both succeed
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", stored.ID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRefreshTokenReused
+		}
+
+		if user.IsGuest {
+			if err := extendGuest(tx, cfg, &user); err != nil {
+				return err
+			}
+		}
+
+		issued, next, err := issueTokens(tx, cfg, &user, &session)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.RefreshToken{}).Where("id = ?", stored.ID).Update("replaced_by_id", next.ID).Error; err != nil {
+			return err
+		}
+
+		// Sliding expiry: an active device stays signed in
+		if err := tx.Model(&session).Updates(map[string]interface{}{
+			"last_seen_at": time.Now(),
+			"ip_address":   c.ClientIP(),
+			"expires_at":   next.ExpiresAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		response = issued
+		return nil
+	})
+
+	if errors.Is(err, errRefreshTokenReused) {
+		return nil, handleRefreshReuse(&stored)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleRefreshReuse(stored *models.RefreshToken) error {
+	log.Printf("Refresh token reuse detected for user %s, revoking session %s", stored.UserID, stored.FamilyID)
+	if err := revokeSessions(database.DB, "id = ?", stored.FamilyID); err != nil {
+		return err
+	}
+	return errRefreshTokenReused
+}
+
+// revokeSessions revokes every session matching the condition together with
+// all refresh tokens belonging to those sessions.
+func revokeSessions(db *gorm.DB, query interface{}, args ...interface{}) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var ids []string
+		if err := tx.Model(&models.Session{}).Where(query, args...).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		if err := tx.Model(&models.Session{}).
+			Where("id IN ? AND revoked_at IS NULL", ids).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.RefreshToken{}).
+			Where("family_id IN ? AND revoked_at IS NULL", ids).
+			Update("revoked_at", now).Error
+	})
+}
+
+// deviceLabelFromUserAgent builds a short human readable label such as
+// "Chrome on Windows" for sessions that were not given one explicitly.
+func deviceLabelFromUserAgent(userAgent string) string {
+	browsers := []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	}
+	systems := []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	}
+
+	browser, system := "", ""
+	for _, b := range browsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, s := range systems {
+		if strings.Contains(userAgent, s.token) {
+			system = s.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	default:
+		return "Unknown device"
+	}
+}
+
+// createUserToken issues a single-use token for the given purpose. Earlier
+// unused tokens of the same purpose are invalidated so only the latest email
+// link works.
+func createUserToken(tx *gorm.DB, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error; err != nil {
+		return "", err
+	}
+
+	record := models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// consumeUserToken marks a valid token as used and returns it. Returns
+// errInvalidUserToken for unknown, expired or already used tokens.
+func consumeUserToken(tx *gorm.DB, token, purpose string) (*models.UserToken, error) {
+	var record models.UserToken
+	if err := tx.Where("token_hash = ? AND purpose = ?", utils.HashToken(token), purpose).First(&record).Error; err != nil {
+		return nil, errInvalidUserToken
+	}
+
+	if record.UsedAt != nil || time.Now().After(record.ExpiresAt) {
+		return nil, errInvalidUserToken
+	}
+
+	result := tx.Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", record.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errInvalidUserToken
+	}
+
+	return &record, nil
+}
@@ -1,13 +1,29 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"time"
+	"english-learning-app/internal/achievements"
+	"english-learning-app/internal/config"
 	"english-learning-app/internal/database"
 	"english-learning-app/internal/models"
+	"english-learning-app/internal/points"
+	"english-learning-app/internal/progression"
+	"english-learning-app/internal/streaks"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+type ProfileResponse struct {
+	models.User
+	Daily *streaks.Status `json:"daily"`
+}
+
 func GetProfile(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -24,7 +40,13 @@ func GetProfile(c *gin.Context) {
 	// Clear password
 	user.Password = ""
 
-	c.JSON(http.StatusOK, user)
+	daily, err := streaks.Today(database.DB, &user, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch daily progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProfileResponse{User: user, Daily: daily})
 }
 
 func UpdateProfile(c *gin.Context) {
@@ -35,8 +57,9 @@ func UpdateProfile(c *gin.Context) {
 	}
 
 	var req struct {
-		Name   string `json:"name"`
-		Avatar string `json:"avatar"`
+		Name     string `json:"name"`
+		Avatar   string `json:"avatar"`
+		TimeZone string `json:"time_zone"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -57,8 +80,16 @@ func UpdateProfile(c *gin.Context) {
 	if req.Avatar != "" {
 		user.Avatar = &req.Avatar
 	}
+	if req.TimeZone != "" {
+		if !streaks.ValidTimeZone(req.TimeZone) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown time zone"})
+			return
+		}
+		user.TimeZone = req.TimeZone
+	}
 
-	if err := database.DB.Save(&user).Error; err != nil {
+	// Only the profile columns, so a stale copy of e.g. points is not written back
+	if err := database.DB.Model(&user).Select("name", "avatar", "time_zone").Updates(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
 		return
 	}
@@ -85,6 +116,9 @@ func GetUserProgress(c *gin.Context) {
 	c.JSON(http.StatusOK, progress)
 }
 
+// CompleteTopic scores the topic from the user's exercise attempts. A passing
+// score marks the topic completed, and points are awarded only for the part
+// of the score that beats the previous best.
 func CompleteTopic(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -94,7 +128,6 @@ func CompleteTopic(c *gin.Context) {
 
 	var req struct {
 		TopicID uuid.UUID `json:"topic_id" binding:"required"`
-		Score   int       `json:"score"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -102,44 +135,103 @@ func CompleteTopic(c *gin.Context) {
 		return
 	}
 
-	// Check if progress already exists
-	var existingProgress models.UserProgress
-	err := database.DB.Where("user_id = ? AND topic_id = ?", userID, req.TopicID).First(&existingProgress).Error
+	var topic models.Topic
+	if err := database.DB.Where("id = ?", req.TopicID).First(&topic).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Topic not found"})
+		return
+	}
 
-	if err == nil {
-		// Update existing progress
-		existingProgress.Completed = true
-		existingProgress.Score = req.Score
-		if err := database.DB.Save(&existingProgress).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update progress"})
-			return
+	cfg := config.LoadConfig()
+
+	var result *TopicResult
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the user so concurrent completions see each other's progress
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", userID).First(&user).Error; err != nil {
+			return err
 		}
-	} else {
-		// Create new progress
-		progress := models.UserProgress{
-			UserID:    userID.(uuid.UUID),
-			TopicID:   req.TopicID,
-			Completed: true,
-			Score:     req.Score,
+
+		var err error
+		result, err = scoreTopic(tx, user.ID, topic.ID, cfg.Progress.PassThreshold)
+		if err != nil {
+			return err
 		}
-		if err := database.DB.Create(&progress).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create progress"})
-			return
+
+		var progress models.UserProgress
+		err = tx.Where("user_id = ? AND topic_id = ?", user.ID, topic.ID).First(&progress).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		exists := err == nil
+		if exists && progress.Completed {
+			result.PreviousBest = progress.Score
 		}
-	}
 
-	// Update user points
-	var user models.User
-	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		if !result.Passed {
+			return nil
+		}
+
+		// A passed topic is always completed, even one worth no points; only
+		// the award depends on beating the previous best
+		improved := result.Score > result.PreviousBest
+		if exists && progress.Completed && !progress.TestedOut && !improved {
+			return nil
+		}
+
+		now := time.Now()
+		if exists {
+			updates := map[string]interface{}{"completed": true, "tested_out": false}
+			if improved {
+				updates["score"] = result.Score
+			}
+			if progress.CompletedAt == nil {
+				updates["completed_at"] = now
+			}
+			if err := tx.Model(&progress).Updates(updates).Error; err != nil {
+				return err
+			}
+		} else {
+			progress = models.UserProgress{
+				UserID:      user.ID,
+				TopicID:     topic.ID,
+				Completed:   true,
+				Score:       result.Score,
+				CompletedAt: &now,
+			}
+			if err := tx.Create(&progress).Error; err != nil {
+				return err
+			}
+		}
+
+		if improved {
+			result.PointsAwarded = result.Score - result.PreviousBest
+			// Keyed by the new best score, so a retried request cannot award
+			// the same improvement twice
+			if _, err := points.Award(tx, &models.PointsEntry{
+				UserID:         user.ID,
+				SourceType:     models.PointsSourceTopicCompletion,
+				SourceID:       &topic.ID,
+				Delta:          result.PointsAwarded,
+				IdempotencyKey: fmt.Sprintf("topic_completion:%s:%s:%d", user.ID, topic.ID, result.Score),
+			}); err != nil {
+				return err
+			}
+		}
+
+		result.LevelUps, err = progression.Evaluate(tx, &user)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete topic"})
 		return
 	}
 
-	user.Points += req.Score
-	if err := database.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user points"})
-		return
+	joinWeeklyLeague(userID.(uuid.UUID))
+
+	result.AchievementsUnlocked, err = achievements.Evaluate(database.DB, userID.(uuid.UUID), achievements.EventTopicCompleted)
+	if err != nil {
+		log.Printf("Failed to evaluate achievements for user %s: %v", userID, err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Topic completed successfully"})
+	c.JSON(http.StatusOK, result)
 }
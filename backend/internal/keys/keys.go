@@ -0,0 +1,62 @@
+package keys
+
+import (
+	"english-learning-app/internal/config"
+	"english-learning-app/pkg/utils"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Default is the key set used to sign and verify tokens. It is set by Setup on
+// startup.
+var Default *utils.KeySet
+
+// insecureSecrets are JWT secrets that ship with the code or the example env
+// file and must never protect a real deployment.
+var insecureSecrets = map[string]bool{
+	"":                true,
+	"your-secret-key": true,
+	"your-super-secret-jwt-key-change-in-production": true,
+}
+
+// Setup builds the key set for the configured algorithm. Unless APP_ENV is
+// explicitly set to development it refuses to start with a well-known HS256
+// secret or without key files.
+func Setup(cfg *config.Config) error {
+	development := cfg.App.Env == "development"
+
+	switch cfg.JWT.Algorithm {
+	case "HS256":
+		if insecureSecrets[cfg.JWT.SecretKey] {
+			if !development {
+				return errors.New("JWT_SECRET is not set or uses a default value; refusing to start outside development")
+			}
+			log.Println("WARNING: signing tokens with a default JWT secret, do not use this outside development")
+		}
+		Default = utils.NewHMACKeySet(cfg.JWT.SecretKey)
+	case "RS256", "EdDSA":
+		if cfg.JWT.KeysDir == "" {
+			if !development {
+				return errors.New("JWT_KEYS_DIR is required outside development")
+			}
+			keySet, err := utils.GenerateKeySet(cfg.JWT.Algorithm)
+			if err != nil {
+				return err
+			}
+			log.Println("WARNING: no JWT_KEYS_DIR set, signing with an ephemeral key; tokens will not survive a restart")
+			Default = keySet
+		} else {
+			keySet, err := utils.LoadKeySet(cfg.JWT.KeysDir, cfg.JWT.ActiveKeyID)
+			if err != nil {
+				return err
+			}
+			Default = keySet
+		}
+	default:
+		return fmt.Errorf("unsupported JWT_ALGORITHM %q", cfg.JWT.Algorithm)
+	}
+
+	log.Printf("Signing tokens with %s (key %q)", cfg.JWT.Algorithm, Default.ActiveKeyID())
+	return nil
+}
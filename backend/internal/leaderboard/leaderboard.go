@@ -0,0 +1,281 @@
+// Package leaderboard ranks learners by the points they earned in a time
+// window. Scores per period are kept in leaderboard_scores, updated with
+// every ledger award and checked against the ledger on a schedule.
+package leaderboard
+
+import (
+	"encoding/base64"
+	"english-learning-app/internal/models"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Windows.
+const (
+	WindowWeek  = "week"
+	WindowMonth = "month"
+	WindowAll   = "all"
+)
+
+// Spending points, e.g. on streak freezes, does not lower a learner's
+// standing.
+var excludedSources = []string{models.PointsSourceStreakFreeze}
+
+// Sources only counted all-time. The opening balance carries points earned
+// before the ledger existed and is dated when it was backfilled, so it would
+// otherwise show up as that week's and month's earnings.
+var allTimeSources = []string{models.PointsSourceOpeningBalance}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// WindowStart returns when the window containing now began: Monday 00:00 UTC
+// for weeks, the 1st 00:00 UTC for months and the zero time for all-time.
+func WindowStart(window string, now time.Time) (time.Time, bool) {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch window {
+	case WindowWeek:
+		offset := (int(today.Weekday()) + 6) % 7
+		return today.AddDate(0, 0, -offset), true
+	case WindowMonth:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), true
+	case WindowAll:
+		return time.Time{}, true
+	}
+	return time.Time{}, false
+}
+
+// Period returns the key under which scores of the window containing t are
+// stored.
+func Period(window string, t time.Time) string {
+	start, _ := WindowStart(window, t)
+	switch window {
+	case WindowWeek:
+		return "week:" + start.Format("2006-01-02")
+	case WindowMonth:
+		return "month:" + start.Format("2006-01")
+	}
+	return WindowAll
+}
+
+// periodsAt returns every period a ledger entry created at t counts towards.
+func periodsAt(t time.Time) []string {
+	return []string{Period(WindowAll, t), Period(WindowWeek, t), Period(WindowMonth, t)}
+}
+
+// periodRange returns the time range of a period key; both ends are zero for
+// all-time.
+func periodRange(period string) (time.Time, time.Time, error) {
+	window, date, _ := strings.Cut(period, ":")
+	switch window {
+	case WindowAll:
+		return time.Time{}, time.Time{}, nil
+	case WindowWeek:
+		start, err := time.Parse("2006-01-02", date)
+		return start, start.AddDate(0, 0, 7), err
+	case WindowMonth:
+		start, err := time.Parse("2006-01", date)
+		return start, start.AddDate(0, 1, 0), err
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("unknown leaderboard period %q", period)
+}
+
+type Entry struct {
+	Rank            int       `json:"rank"`
+	UserID          uuid.UUID `json:"user_id"`
+	Name            string    `json:"name"`
+	Avatar          *string   `json:"avatar"`
+	Level           string    `json:"level"`
+	Points          int       `json:"points"`
+	TopicsCompleted int       `json:"topics_completed"`
+}
+
+// Cursor marks the last entry of a page. Pages are ordered by points
+// descending, then user ID; the rank lets the next page continue numbering
+// without counting the entries before it.
+type Cursor struct {
+	Rank   int
+	Points int
+	UserID uuid.UUID
+}
+
+func (c Cursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d:%s", c.Rank, c.Points, c.UserID)))
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), ":", 3)
+	if len(parts) != 3 {
+		return nil, ErrInvalidCursor
+	}
+	c := &Cursor{}
+	if c.Rank, err = strconv.Atoi(parts[0]); err != nil || c.Rank < 1 {
+		return nil, ErrInvalidCursor
+	}
+	if c.Points, err = strconv.Atoi(parts[1]); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.UserID, err = uuid.Parse(parts[2]); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Query selects the learners to rank.
+type Query struct {
+	Period string // see Period
+	Level  string // CEFR level filter, empty for all
+	// Members restricts the ranking to the user IDs the subquery selects,
+	// e.g. friends or a classroom. Members without points in the period rank
+	// with zero. Nil ranks everyone who earned points in the period.
+	Members *gorm.DB
+}
+
+// scores returns the SQL and arguments of a FROM/WHERE clause exposing the
+// learners in scope with their points. The global ranking walks the
+// (period, points, user_id) index from the cursor, so reading a page costs
+// the page size. Scoped rankings start from the members instead, which are
+// few.
+func (q *Query) scores() (string, []interface{}) {
+	var sql string
+	var args []interface{}
+	if q.Members != nil {
+		sql = ` FROM users u LEFT JOIN leaderboard_scores s ON s.user_id = u.id AND s.period = ?
+			WHERE u.id IN (?)`
+		args = append(args, q.Period, q.Members)
+	} else {
+		sql = ` FROM leaderboard_scores s JOIN users u ON u.id = s.user_id
+			WHERE s.period = ?`
+		args = append(args, q.Period)
+	}
+
+	sql += ` AND u.deleted_at IS NULL AND u.is_guest = false`
+	if q.Level != "" {
+		sql += ` AND u.level = ?`
+		args = append(args, q.Level)
+	}
+	return sql, args
+}
+
+// rankColumns returns the expressions learners are ranked by. The global
+// ranking uses the bare score columns, as Postgres cannot use the index for
+// an expression over them.
+func (q *Query) rankColumns() (points, id string) {
+	if q.Members != nil {
+		return "COALESCE(s.points, 0)", "u.id"
+	}
+	return "s.points", "s.user_id"
+}
+
+func (q *Query) entryColumns() string {
+	points, _ := q.rankColumns()
+	return `SELECT u.id AS user_id, u.name, u.avatar, u.level, ` + points + ` AS points`
+}
+
+func (q *Query) orderColumns() string {
+	points, id := q.rankColumns()
+	return ` ORDER BY ` + points + ` DESC, ` + id
+}
+
+// aheadOf and behind take the points twice and the user ID. The first bound
+// on points alone lets the index scan start at the cursor; the rest breaks
+// ties.
+func (q *Query) aheadOf() string {
+	points, id := q.rankColumns()
+	return fmt.Sprintf(` AND %[1]s >= ? AND (%[1]s > ? OR %[2]s < ?)`, points, id)
+}
+
+func (q *Query) behind() string {
+	points, id := q.rankColumns()
+	return fmt.Sprintf(` AND %[1]s <= ? AND (%[1]s < ? OR %[2]s > ?)`, points, id)
+}
+
+// Page returns up to limit entries following after, or the top entries when
+// after is nil.
+func Page(db *gorm.DB, q *Query, after *Cursor, limit int) ([]Entry, error) {
+	from, args := q.scores()
+	sql := q.entryColumns() + from
+	rank := 0
+	if after != nil {
+		sql += q.behind()
+		args = append(args, after.Points, after.Points, after.UserID)
+		rank = after.Rank
+	}
+	sql += q.orderColumns() + ` LIMIT ?`
+	args = append(args, limit)
+
+	entries := []Entry{}
+	if err := db.Raw(sql, args...).Scan(&entries).Error; err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entries[i].Rank = rank + i + 1
+	}
+	return entries, withTopics(db, entries)
+}
+
+// RankOf returns the user's own entry, or nil if they are not in scope or
+// earned nothing in the period of a global ranking. The rank is found by
+// counting the learners ahead, so it costs O(rank) rather than a page.
+func RankOf(db *gorm.DB, q *Query, userID uuid.UUID) (*Entry, error) {
+	from, args := q.scores()
+	var entries []Entry
+	if err := db.Raw(q.entryColumns()+from+` AND u.id = ?`, append(args, userID)...).Scan(&entries).Error; err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	entry := &entries[0]
+
+	from, args = q.scores()
+	var ahead int
+	args = append(args, entry.Points, entry.Points, userID)
+	if err := db.Raw(`SELECT COUNT(*)`+from+q.aheadOf(), args...).Scan(&ahead).Error; err != nil {
+		return nil, err
+	}
+	entry.Rank = ahead + 1
+	return entry, withTopics(db, entries)
+}
+
+// withTopics fills in the completed topic counts of the entries.
+func withTopics(db *gorm.DB, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(entries))
+	for i, e := range entries {
+		ids[i] = e.UserID
+	}
+
+	var counts []struct {
+		UserID uuid.UUID
+		Count  int
+	}
+	if err := db.Model(&models.UserProgress{}).
+		Select("user_id, COUNT(*) AS count").
+		Where("user_id IN ? AND completed = ?", ids, true).
+		Group("user_id").
+		Scan(&counts).Error; err != nil {
+		return err
+	}
+
+	byUser := make(map[uuid.UUID]int, len(counts))
+	for _, c := range counts {
+		byUser[c.UserID] = c.Count
+	}
+	for i := range entries {
+		entries[i].TopicsCompleted = byUser[entries[i].UserID]
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package leaderboard
+
+import (
+	"english-learning-app/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Record adds a new ledger entry to the stored scores of every period it
+// falls in. It must run in the transaction that writes the entry.
+func Record(tx *gorm.DB, entry *models.PointsEntry) error {
+	for _, source := range excludedSources {
+		if entry.SourceType == source {
+			return nil
+		}
+	}
+
+	periods := periodsAt(entry.CreatedAt)
+	for _, source := range allTimeSources {
+		if entry.SourceType == source {
+			periods = []string{WindowAll}
+		}
+	}
+
+	for _, period := range periods {
+		if err := tx.Exec(`INSERT INTO leaderboard_scores (period, user_id, points, updated_at)
+			VALUES (?, ?, ?, NOW())
+			ON CONFLICT (period, user_id) DO UPDATE
+			SET points = leaderboard_scores.points + EXCLUDED.points, updated_at = EXCLUDED.updated_at`,
+			period, entry.UserID, entry.Delta).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Drift is a user whose stored score differs from their ledger.
+type Drift struct {
+	UserID uuid.UUID `json:"user_id"`
+	Stored int       `json:"stored"`
+	Ledger int       `json:"ledger"`
+}
+
+// ledgerScores returns the SQL and arguments of a query summing each user's
+// ledger entries in period, the source of truth for stored scores.
+func ledgerScores(period string) (string, []interface{}, error) {
+	start, end, err := periodRange(period)
+	if err != nil {
+		return "", nil, err
+	}
+
+	sql := `SELECT user_id, SUM(delta) AS points FROM points_entries WHERE source_type NOT IN ?`
+	args := []interface{}{excludedSources}
+	if !start.IsZero() {
+		sql += ` AND source_type NOT IN ? AND created_at >= ? AND created_at < ?`
+		args = append(args, allTimeSources, start, end)
+	}
+	sql += ` GROUP BY user_id`
+	return sql, args, nil
+}
+
+// Check compares the stored scores of period with the ledger and returns
+// every user whose score is missing, stale or orphaned.
+func Check(db *gorm.DB, period string) ([]Drift, error) {
+	ledger, args, err := ledgerScores(period)
+	if err != nil {
+		return nil, err
+	}
+
+	drift := []Drift{}
+	err = db.Raw(`SELECT COALESCE(s.user_id, l.user_id) AS user_id,
+			COALESCE(s.points, 0) AS stored, COALESCE(l.points, 0) AS ledger
+		FROM (SELECT user_id, points FROM leaderboard_scores WHERE period = ?) s
+		FULL OUTER JOIN (`+ledger+`) l ON l.user_id = s.user_id
+		WHERE s.user_id IS NULL OR l.user_id IS NULL OR s.points <> l.points
+		ORDER BY 1`,
+		append([]interface{}{period}, args...)...).Scan(&drift).Error
+	return drift, err
+}
+
+// Rebuild replaces the stored scores of period with the ledger sums.
+func Rebuild(db *gorm.DB, period string) error {
+	ledger, args, err := ledgerScores(period)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Blocks concurrent Record calls until the rebuild commits. An award
+		// that recorded first has committed by the time the lock is granted
+		// and is counted below; one that records later adds on top.
+		if err := tx.Exec("LOCK TABLE leaderboard_scores IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+		if err := tx.Where("period = ?", period).Delete(&models.LeaderboardScore{}).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`INSERT INTO leaderboard_scores (period, user_id, points, updated_at)
+			SELECT ?, l.user_id, l.points, NOW() FROM (`+ledger+`) l`,
+			append([]interface{}{period}, args...)...).Error
+	})
+}
+
+// RebuildUser replaces one user's stored scores with their ledger sums in
+// every period they have points in.
+func RebuildUser(tx *gorm.DB, userID uuid.UUID) error {
+	var entries []models.PointsEntry
+	if err := tx.Select("source_type", "delta", "created_at").
+		Where("user_id = ?", userID).
+		Find(&entries).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("user_id = ?", userID).Delete(&models.LeaderboardScore{}).Error; err != nil {
+		return err
+	}
+	for i := range entries {
+		entries[i].UserID = userID
+		if err := Record(tx, &entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sync checks the periods in use at now against the ledger and rebuilds the
+// ones that drifted, returning the number of users that were off. Scores of
+// periods that ended long ago are dropped.
+func Sync(db *gorm.DB, now time.Time) (int, error) {
+	drifted := 0
+	for _, period := range append(periodsAt(now), Period(WindowWeek, now.AddDate(0, 0, -7))) {
+		drift, err := Check(db, period)
+		if err != nil {
+			return drifted, err
+		}
+		if len(drift) == 0 {
+			continue
+		}
+		if err := Rebuild(db, period); err != nil {
+			return drifted, err
+		}
+		drifted += len(drift)
+	}
+
+	// Week keys sort by date, so older weeks compare lower
+	err := db.Where("period LIKE 'week:%' AND period < ?", Period(WindowWeek, now.AddDate(0, 0, -28))).
+		Or("period LIKE 'month:%' AND period < ?", Period(WindowMonth, now.AddDate(-1, 0, 0))).
+		Delete(&models.LeaderboardScore{}).Error
+	return drifted, err
+}
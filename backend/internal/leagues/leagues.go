@@ -0,0 +1,222 @@
+// Package leagues groups active learners into weekly cohorts within tiers and
+// moves the top and bottom finishers between tiers when the week closes.
+package leagues
+
+import (
+	"english-learning-app/internal/config"
+	"english-learning-app/internal/leaderboard"
+	"english-learning-app/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Tiers from lowest to highest. Everyone starts in the first.
+var Tiers = []string{"Bronze", "Silver", "Gold", "Sapphire", "Ruby", "Emerald", "Diamond"}
+
+// Policy controls cohort sizes and movement between tiers.
+type Policy struct {
+	CohortSize    int
+	PromoteCount  int
+	RelegateCount int
+}
+
+func PolicyFromConfig(cfg *config.Config) Policy {
+	return Policy{
+		CohortSize:    cfg.League.CohortSize,
+		PromoteCount:  cfg.League.PromoteCount,
+		RelegateCount: cfg.League.RelegateCount,
+	}
+}
+
+// WeekStart returns Monday 00:00 UTC of the week containing t, matching the
+// weekly leaderboard window.
+func WeekStart(t time.Time) time.Time {
+	start, _ := leaderboard.WindowStart(leaderboard.WindowWeek, t)
+	return start
+}
+
+// TierName returns the display name of a tier.
+func TierName(tier int) string {
+	if tier < 0 || tier >= len(Tiers) {
+		return ""
+	}
+	return Tiers[tier]
+}
+
+// Current returns the user's membership for the week containing now, or nil
+// if they have not joined a league this week.
+func Current(db *gorm.DB, userID uuid.UUID, now time.Time) (*models.LeagueMembership, error) {
+	var membership models.LeagueMembership
+	err := db.Preload("League").
+		Where("user_id = ? AND week_start = ?", userID, WeekStart(now)).
+		First(&membership).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// Join places the user in a league for the week containing now unless they
+// already have one. The tier follows from last week's outcome; the user fills
+// the first league of that tier with room, or a new league is opened.
+func Join(db *gorm.DB, userID uuid.UUID, now time.Time, policy Policy) (*models.LeagueMembership, error) {
+	if membership, err := Current(db, userID, now); err != nil || membership != nil {
+		return membership, err
+	}
+
+	week := WeekStart(now)
+	tier, err := nextTier(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Serialize joins per week and tier so cohorts never exceed the size
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", fmt.Sprintf("league:%s:%d", week.Format("2006-01-02"), tier)).Error; err != nil {
+			return err
+		}
+
+		var league models.League
+		err := tx.Where("week_start = ? AND tier = ? AND (?) < ?", week, tier,
+			tx.Model(&models.LeagueMembership{}).Select("COUNT(*)").Where("league_memberships.league_id = leagues.id"),
+			policy.CohortSize,
+		).Order("number").First(&league).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			var number int
+			if err := tx.Model(&models.League{}).Where("week_start = ? AND tier = ?", week, tier).
+				Select("COALESCE(MAX(number), 0)").Scan(&number).Error; err != nil {
+				return err
+			}
+			league = models.League{WeekStart: week, Tier: tier, Number: number + 1}
+			err = tx.Create(&league).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LeagueMembership{
+			LeagueID:  league.ID,
+			UserID:    userID,
+			WeekStart: week,
+			Tier:      tier,
+			JoinedAt:  now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return Current(db, userID, now)
+}
+
+// nextTier is the tier the user earned in their latest closed week, or the
+// lowest tier for newcomers.
+func nextTier(db *gorm.DB, userID uuid.UUID) (int, error) {
+	var last models.LeagueMembership
+	err := db.Where("user_id = ? AND next_tier IS NOT NULL", userID).Order("week_start DESC").First(&last).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return *last.NextTier, nil
+}
+
+// Standings ranks the members of a league by points earned during its week.
+func Standings(db *gorm.DB, league *models.League) ([]leaderboard.Entry, error) {
+	return leaderboard.Page(db, standingsQuery(db, league), nil, 1000)
+}
+
+func standingsQuery(db *gorm.DB, league *models.League) *leaderboard.Query {
+	return &leaderboard.Query{
+		Period:  leaderboard.Period(leaderboard.WindowWeek, league.WeekStart),
+		Members: db.Model(&models.LeagueMembership{}).Select("user_id").Where("league_id = ?", league.ID),
+	}
+}
+
+// Outcome decides what happens to the member finishing at rank out of size
+// in tier. Nobody is promoted without earning points.
+func (p Policy) Outcome(tier, rank, size, points int) (string, int) {
+	switch {
+	case rank <= p.PromoteCount && points > 0 && tier < len(Tiers)-1:
+		return models.LeagueOutcomePromoted, tier + 1
+	case rank > size-p.RelegateCount && rank > p.PromoteCount && tier > 0:
+		return models.LeagueOutcomeRelegated, tier - 1
+	}
+	return models.LeagueOutcomeStayed, tier
+}
+
+// CloseEndedWeeks closes every league whose week ended before now and
+// returns how many were closed. Closing is idempotent: a league that is
+// already closed is skipped, so the job can run on several instances.
+func CloseEndedWeeks(db *gorm.DB, now time.Time, policy Policy) (int, error) {
+	var ids []uuid.UUID
+	if err := db.Model(&models.League{}).
+		Where("closed_at IS NULL AND week_start < ?", WeekStart(now)).
+		Order("week_start").
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	closed := 0
+	for _, id := range ids {
+		ok, err := closeLeague(db, id, now, policy)
+		if err != nil {
+			log.Printf("Failed to close league %s: %v", id, err)
+			continue
+		}
+		if ok {
+			closed++
+		}
+	}
+	return closed, nil
+}
+
+func closeLeague(db *gorm.DB, id uuid.UUID, now time.Time, policy Policy) (bool, error) {
+	closed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var league models.League
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND closed_at IS NULL", id).
+			First(&league).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		standings, err := leaderboard.Page(tx, standingsQuery(tx, &league), nil, 1000)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range standings {
+			outcome, next := policy.Outcome(league.Tier, entry.Rank, len(standings), entry.Points)
+			rank, points := entry.Rank, entry.Points
+			if err := tx.Model(&models.LeagueMembership{}).
+				Where("league_id = ? AND user_id = ?", league.ID, entry.UserID).
+				Updates(map[string]interface{}{
+					"final_rank": rank,
+					"points":     points,
+					"outcome":    outcome,
+					"next_tier":  next,
+				}).Error; err != nil {
+				return err
+			}
+		}
+
+		closed = true
+		return tx.Model(&league).Update("closed_at", now).Error
+	})
+	return closed, err
+}
@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"english-learning-app/internal/config"
+	"errors"
+	"fmt"
+	"log"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional email such as verification links.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Default is the mailer used by the handlers. It is set by Setup on startup.
+var Default Mailer
+
+// Setup selects the mailer implementation from the configured driver.
+func Setup(cfg *config.Config) error {
+	switch cfg.Mail.Driver {
+	case "smtp":
+		Default = NewSMTPMailer(cfg.Mail)
+	case "file":
+		Default = NewFileMailer(cfg.Mail.FileDir, cfg.Mail.From)
+	case "memory":
+		Default = NewMemoryMailer()
+	default:
+		return fmt.Errorf("unknown mail driver %q", cfg.Mail.Driver)
+	}
+
+	log.Printf("Mailer configured with %s driver", cfg.Mail.Driver)
+	return nil
+}
+
+// Send delivers msg through the Default mailer.
+func Send(msg Message) error {
+	if Default == nil {
+		return errors.New("mailer is not configured")
+	}
+	return Default.Send(msg)
+}
@@ -2,9 +2,13 @@ package middleware
 
 import (
 	"english-learning-app/internal/config"
+	"english-learning-app/internal/database"
+	"english-learning-app/internal/keys"
+	"english-learning-app/internal/models"
 	"english-learning-app/pkg/utils"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -25,21 +29,173 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		claims, err := utils.ValidateToken(tokenString, cfg.JWT.SecretKey)
-		if err != nil {
+		if strings.HasPrefix(tokenString, models.PersonalAccessTokenPrefix) {
+			authenticatePersonalAccessToken(c, tokenString)
+			return
+		}
+
+		claims, err := utils.ValidateToken(tokenString, keys.Default)
+		if err != nil || claims.TokenType != utils.TokenTypeAccess {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
+		// Tokens of a logged out, revoked or expired session stop working immediately
+		var session models.Session
+		if err := database.DB.Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > now()", claims.SessionID, claims.UserID).First(&session).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired or been revoked"})
+			c.Abort()
+			return
+		}
+
+		// Throttle last-seen writes to one per minute per session
+		if time.Since(session.LastSeenAt) > time.Minute {
+			database.DB.Model(&session).Updates(map[string]interface{}{
+				"last_seen_at": time.Now(),
+				"ip_address":   c.ClientIP(),
+			})
+		}
+
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
+		c.Set("user_roles", claims.Roles)
+		c.Set("session_id", claims.SessionID)
+		c.Next()
+	}
+}
+
+// authenticatePersonalAccessToken authenticates a request made with a personal
+// access token. Roles are loaded fresh on every request so revoking a role
+// also narrows the user's tokens.
+func authenticatePersonalAccessToken(c *gin.Context, token string) {
+	var pat models.PersonalAccessToken
+	if err := database.DB.Where("token_hash = ? AND revoked_at IS NULL", utils.HashToken(token)).First(&pat).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Abort()
+		return
+	}
+
+	if pat.ExpiresAt != nil && time.Now().After(*pat.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has expired"})
+		c.Abort()
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", pat.UserID).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Abort()
+		return
+	}
+
+	roles, err := models.LoadUserRoles(database.DB, &user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load roles"})
+		c.Abort()
+		return
+	}
+
+	// Throttle last-used writes to one per minute per token
+	if pat.LastUsedAt == nil || time.Since(*pat.LastUsedAt) > time.Minute {
+		database.DB.Model(&pat).Update("last_used_at", time.Now())
+	}
+
+	c.Set("user_id", user.ID)
+	c.Set("user_email", user.Email)
+	c.Set("user_roles", roles)
+	c.Set("token_scopes", pat.Scopes)
+	c.Next()
+}
+
+// RequireSession rejects requests authenticated with a personal access token.
+// Used for everything outside the scoped admin API, such as account settings.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isToken := c.Get("token_scopes"); isToken {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Personal access tokens cannot be used for this endpoint"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRegistered rejects guest accounts. Guests may only browse lessons
+// and submit exercises until they upgrade to a full account.
+func RequireRegistered() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roles, _ := c.Get("user_roles")
+		if roles, ok := roles.([]string); ok {
+			for _, role := range roles {
+				if role == models.RoleGuest {
+					c.JSON(http.StatusForbidden, gin.H{"error": "Create an account to use this feature"})
+					c.Abort()
+					return
+				}
+			}
+		}
+
 		c.Next()
 	}
 }
 
-func AdminMiddleware() gin.HandlerFunc {
+// RequirePermission allows the request only if one of the caller's roles
+// grants the permission and, for personal access tokens, the token has the
+// permission among its scopes. Must run after AuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		roles, exists := c.Get("user_roles")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		if !models.HasPermission(roles.([]string), permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+
+		if scopes, isToken := c.Get("token_scopes"); isToken && !hasScope(scopes.([]string), permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Token is missing the required scope", "scope": permission})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func hasScope(scopes []string, permission string) bool {
+	for _, scope := range scopes {
+		if scope == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireVerifiedEmail blocks users who have not verified their email address
+// from a feature listed in the verification policy. Features not listed in
+// the policy pass through. Must run after AuthMiddleware.
+func RequireVerifiedEmail(cfg *config.Config, feature string) gin.HandlerFunc {
+	restricted := false
+	for _, f := range cfg.Verification.RestrictedFeatures {
+		if f == feature {
+			restricted = true
+			break
+		}
+	}
+
+	return func(c *gin.Context) {
+		if !restricted {
+			c.Next()
+			return
+		}
+
 		userID, exists := c.Get("user_id")
 		if !exists {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
@@ -47,10 +203,15 @@ func AdminMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Check if user is admin (you can add this check based on your user model)
-		// For now, we'll just check if user exists
-		if userID == "" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		var user models.User
+		if err := database.DB.Select("id", "email_verified_at").Where("id = ?", userID).First(&user).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
+
+		if user.EmailVerifiedAt == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email verification required", "code": "email_unverified"})
 			c.Abort()
 			return
 		}
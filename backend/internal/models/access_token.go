@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token so it can be
+// told apart from a JWT (and found by secret scanners).
+const PersonalAccessTokenPrefix = "enup_pat_"
+
+// PersonalAccessToken is a long-lived token for scripting the API. It acts
+// with the intersection of its Scopes and the owner's current permissions.
+// Only the SHA-256 hash of the token is stored.
+type PersonalAccessToken struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID      uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	Name        string     `json:"name" gorm:"not null"`
+	TokenHash   string     `json:"-" gorm:"not null;uniqueIndex"`
+	TokenPrefix string     `json:"token_prefix"` // first characters, to recognise a token in lists
+	Scopes      []string   `json:"scopes" gorm:"type:jsonb;serializer:json"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -14,6 +14,11 @@ type Level struct {
 	IsActive    bool      `json:"is_active" gorm:"default:true"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Promotion to the next level: the share of the level's active topics
+	// completed and the average topic score across them, both in percent
+	MinTopicsPercent int `json:"min_topics_percent" gorm:"not null;default:80"`
+	MinAverageScore  int `json:"min_average_score" gorm:"not null;default:70"`
 	
 	// Relations
 	Topics []Topic `json:"topics,omitempty" gorm:"foreignKey:LevelID"`
@@ -40,10 +45,13 @@ type Topic struct {
 type Exercise struct {
 	ID          uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
 	TopicID     uuid.UUID `json:"topic_id" gorm:"type:uuid;not null"`
-	Type        string    `json:"type" gorm:"not null"` // multiple_choice, fill_blank, translation, audio
+	Type        string    `json:"type" gorm:"not null"` // see the Exercise* constants
 	Question    string    `json:"question" gorm:"not null"`
 	Options     []string  `json:"options" gorm:"type:jsonb"` // For multiple choice
 	CorrectAnswer string  `json:"correct_answer" gorm:"not null"`
+	AcceptedAnswers []string `json:"accepted_answers,omitempty" gorm:"type:jsonb;serializer:json"` // also graded as correct
+	Payload     *ExercisePayload `json:"payload,omitempty" gorm:"type:jsonb;serializer:json"` // structured types only
+	Prompt      *ExercisePrompt  `json:"prompt,omitempty" gorm:"-"` // set when shown to learners
 	Explanation string    `json:"explanation"`
 	Points      int       `json:"points" gorm:"default:10"`
 	Order       int       `json:"order" gorm:"not null"`
@@ -64,6 +72,11 @@ type ExerciseAttempt struct {
 	Score       int       `json:"score"`
 	AttemptedAt time.Time `json:"attempted_at"`
 	CreatedAt   time.Time `json:"created_at"`
+
+	// Set when the attempt was made as part of a quiz session
+	QuizSessionID *uuid.UUID `json:"quiz_session_id" gorm:"type:uuid;index"`
+	// Blanks of a cloze exercise answered wrongly, numbered from 1
+	WrongBlanks []int `json:"wrong_blanks,omitempty" gorm:"type:jsonb;serializer:json"`
 	
 	// Relations
 	User     User     `json:"user,omitempty"`
@@ -0,0 +1,48 @@
+package models
+
+// Exercise types. The free-text types are graded against CorrectAnswer and
+// AcceptedAnswers; the structured ones keep their data in Payload.
+const (
+	ExerciseMultipleChoice = "multiple_choice"
+	ExerciseFillBlank      = "fill_blank"
+	ExerciseTranslation    = "translation"
+	ExerciseAudio          = "audio"
+	ExerciseTrueFalse      = "true_false"   // CorrectAnswer is "true" or "false"
+	ExerciseMatching       = "matching"     // Payload.Pairs
+	ExerciseWordOrder      = "word_order"   // Payload.Words
+	ExerciseMultiSelect    = "multi_select" // Options and Payload.Correct
+	ExerciseCloze          = "cloze"        // Payload.Blanks, marked {1}, {2}, ... in the question
+)
+
+// ExercisePayload holds the data of the structured exercise types. Only the
+// fields of the exercise's own type are kept.
+type ExercisePayload struct {
+	// Matching: each word and its translation
+	Pairs []MatchingPair `json:"pairs,omitempty"`
+	// Word ordering: the sentence split into words, in the right order
+	Words []string `json:"words,omitempty"`
+	// Multi-select: the options to select
+	Correct []string `json:"correct,omitempty"`
+	// Cloze: the gaps of the passage, in the order of their markers
+	Blanks []ClozeBlank `json:"blanks,omitempty"`
+}
+
+type ClozeBlank struct {
+	Answers []string `json:"answers"`           // accepted answers, the first is shown in reports
+	Options []string `json:"options,omitempty"` // optional word bank to choose from
+}
+
+type MatchingPair struct {
+	Left  string `json:"left"`
+	Right string `json:"right"`
+}
+
+// ExercisePrompt is the part of a structured exercise shown to learners,
+// shuffled so it doesn't give the answer away.
+type ExercisePrompt struct {
+	Left  []string `json:"left,omitempty"`
+	Right []string `json:"right,omitempty"`
+	Words []string `json:"words,omitempty"`
+	// Word banks of a cloze passage, one per blank and empty for typed blanks
+	Blanks [][]string `json:"blanks,omitempty"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity links an account at an external OpenID Connect provider to a
+// local user. Subject is the provider's stable user ID ("sub" claim).
+type UserIdentity struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Provider  string    `json:"provider" gorm:"not null;uniqueIndex:idx_identity_provider_subject"`
+	Subject   string    `json:"-" gorm:"not null;uniqueIndex:idx_identity_provider_subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OAuthState holds the server side of an authorization request between the
+// redirect to the provider and the callback. The PKCE verifier never leaves
+// the server.
+type OAuthState struct {
+	ID           uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	StateHash    string     `json:"-" gorm:"not null;uniqueIndex"`
+	Provider     string     `json:"provider" gorm:"not null"`
+	Nonce        string     `json:"-" gorm:"not null"`
+	CodeVerifier string     `json:"-" gorm:"not null"`
+	LinkUserID   *uuid.UUID `json:"link_user_id" gorm:"type:uuid"` // set when linking to a signed-in account
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
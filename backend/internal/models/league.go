@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// League outcomes recorded when a week is closed.
+const (
+	LeagueOutcomePromoted  = "promoted"
+	LeagueOutcomeStayed    = "stayed"
+	LeagueOutcomeRelegated = "relegated"
+)
+
+// League is one weekly cohort within a tier. Several leagues of the same tier
+// run in parallel each week, told apart by Number.
+type League struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	WeekStart time.Time  `json:"week_start" gorm:"not null;uniqueIndex:idx_league_week_tier_number"`
+	Tier      int        `json:"tier" gorm:"not null;uniqueIndex:idx_league_week_tier_number"`
+	Number    int        `json:"number" gorm:"not null;uniqueIndex:idx_league_week_tier_number"`
+	ClosedAt  *time.Time `json:"closed_at" gorm:"index"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// LeagueMembership places a user in a league for one week. The final fields
+// are filled in when the week is closed.
+type LeagueMembership struct {
+	LeagueID  uuid.UUID `json:"league_id" gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;primaryKey;uniqueIndex:idx_league_member_week"`
+	WeekStart time.Time `json:"week_start" gorm:"not null;uniqueIndex:idx_league_member_week"`
+	Tier      int       `json:"tier" gorm:"not null"`
+	FinalRank *int      `json:"final_rank"`
+	Points    *int      `json:"points"`
+	Outcome   string    `json:"outcome"`
+	NextTier  *int      `json:"next_tier"`
+	JoinedAt  time.Time `json:"joined_at" gorm:"not null;default:now()"`
+
+	// Relations
+	League *League `json:"league,omitempty"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Placement test states.
+const (
+	PlacementInProgress = "in_progress"
+	PlacementCompleted  = "completed"
+)
+
+// PlacementTest is an adaptive test that estimates a new learner's level.
+// ExerciseID is the question currently waiting for an answer.
+type PlacementTest struct {
+	ID           uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID       uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	Status       string     `json:"status" gorm:"not null;default:'in_progress'"`
+	CurrentLevel string     `json:"current_level" gorm:"not null"`
+	ExerciseID   *uuid.UUID `json:"exercise_id" gorm:"type:uuid"`
+	ResultLevel  *string    `json:"result_level"`
+	StartedAt    time.Time  `json:"started_at" gorm:"not null;default:now()"`
+	CompletedAt  *time.Time `json:"completed_at"`
+
+	// Relations
+	Answers []PlacementAnswer `json:"answers,omitempty" gorm:"foreignKey:TestID"`
+}
+
+// PlacementAnswer is one answered question of a placement test, at the
+// level the question was drawn from.
+type PlacementAnswer struct {
+	ID         uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	TestID     uuid.UUID `json:"test_id" gorm:"type:uuid;not null;index"`
+	ExerciseID uuid.UUID `json:"exercise_id" gorm:"type:uuid;not null"`
+	Level      string    `json:"level" gorm:"not null"`
+	Answer     string    `json:"answer"`
+	IsCorrect  bool      `json:"is_correct"`
+	AnsweredAt time.Time `json:"answered_at" gorm:"not null;default:now()"`
+}
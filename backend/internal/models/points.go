@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Points ledger source types.
+const (
+	PointsSourceOpeningBalance  = "opening_balance"
+	PointsSourceTopicCompletion = "topic_completion"
+	PointsSourceAchievement     = "achievement"
+	PointsSourceStreakFreeze    = "streak_freeze"
+	PointsSourceAdjustment      = "admin_adjustment"
+)
+
+// PointsEntry is an append-only ledger row. A user's balance is the sum of
+// their entries; User.Points caches it. IdempotencyKey makes every award safe
+// to retry.
+type PointsEntry struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID         uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	SourceType     string     `json:"source_type" gorm:"not null;index"`
+	SourceID       *uuid.UUID `json:"source_id" gorm:"type:uuid"`
+	Delta          int        `json:"delta" gorm:"not null"`
+	IdempotencyKey string     `json:"idempotency_key" gorm:"not null;uniqueIndex"`
+	Reason         string     `json:"reason"`
+	CreatedBy      *uuid.UUID `json:"created_by" gorm:"type:uuid"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"index"`
+}
+
+// LeaderboardScore is a user's points earned in one leaderboard period, kept
+// up to date by every ledger award so rankings are read straight from an
+// index. Period is "all", "week:YYYY-MM-DD" or "month:YYYY-MM".
+type LeaderboardScore struct {
+	Period    string    `json:"period" gorm:"primaryKey;index:idx_leaderboard_rank,priority:1"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;primaryKey;index:idx_leaderboard_rank,priority:3"`
+	Points    int       `json:"points" gorm:"not null;default:0;index:idx_leaderboard_rank,priority:2,sort:desc"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
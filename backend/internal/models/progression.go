@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reasons a user's level changed.
+const (
+	LevelChangePromotion = "promotion"
+	LevelChangePlacement = "placement"
+)
+
+// UserLevelHistory records every change of User.Level. Level-ups stay
+// pending until the user acknowledges them, so the frontend can celebrate
+// each one once.
+type UserLevelHistory struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID         uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	FromLevel      string     `json:"from_level" gorm:"not null"`
+	ToLevel        string     `json:"to_level" gorm:"not null"`
+	Reason         string     `json:"reason" gorm:"not null"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Quiz session states.
+const (
+	QuizInProgress = "in_progress"
+	QuizFinished   = "finished"
+	QuizExpired    = "expired" // the time limit ran out before it was finished
+)
+
+// QuizSession is one run through a topic's exercises. The exercises, their
+// order and their points are frozen in Items when the session starts.
+type QuizSession struct {
+	ID               uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID           uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	TopicID          uuid.UUID  `json:"topic_id" gorm:"type:uuid;not null"`
+	Status           string     `json:"status" gorm:"not null;default:'in_progress'"`
+	TimeLimitSeconds *int       `json:"time_limit_seconds"`
+	StartedAt        time.Time  `json:"started_at" gorm:"not null"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	FinishedAt       *time.Time `json:"finished_at"`
+	Score            int        `json:"score" gorm:"not null;default:0"`
+	MaxScore         int        `json:"max_score" gorm:"not null;default:0"`
+
+	// Relations
+	Items []QuizItem `json:"items,omitempty" gorm:"foreignKey:SessionID"`
+}
+
+// QuizItem is one question of a quiz session. AttemptID links the answer
+// once the question has been answered.
+type QuizItem struct {
+	SessionID  uuid.UUID  `json:"session_id" gorm:"type:uuid;primaryKey"`
+	Position   int        `json:"position" gorm:"primaryKey;autoIncrement:false"`
+	ExerciseID uuid.UUID  `json:"exercise_id" gorm:"type:uuid;not null"`
+	Points     int        `json:"points" gorm:"not null"`
+	AttemptID  *uuid.UUID `json:"attempt_id" gorm:"type:uuid"`
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Roles. Every user is implicitly a student; the others are granted explicitly.
+// Guest accounts only ever have the guest role, which grants nothing and
+// cannot be granted.
+const (
+	RoleGuest         = "guest"
+	RoleStudent       = "student"
+	RoleTeacher       = "teacher"
+	RoleContentEditor = "content_editor"
+	RoleAdmin         = "admin"
+)
+
+// Permissions checked per route.
+const (
+	PermContentWrite  = "content:write"
+	PermContentDelete = "content:delete"
+	PermUsersRead     = "users:read"
+	PermRolesManage   = "roles:manage"
+	PermSecurityRead  = "security:read"
+	PermPointsManage  = "points:manage"
+	PermClassesManage = "classes:manage"
+)
+
+var RolePermissions = map[string][]string{
+	RoleStudent:       {},
+	RoleTeacher:       {PermContentWrite, PermUsersRead, PermClassesManage},
+	RoleContentEditor: {PermContentWrite, PermContentDelete},
+	RoleAdmin:         {PermContentWrite, PermContentDelete, PermUsersRead, PermRolesManage, PermSecurityRead, PermPointsManage, PermClassesManage},
+}
+
+// IsValidPermission reports whether permission is granted by any role.
+func IsValidPermission(permission string) bool {
+	for role := range RolePermissions {
+		if HasPermission([]string{role}, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidRole reports whether role is one of the known roles.
+func IsValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether any of the roles grants the permission.
+func HasPermission(roles []string, permission string) bool {
+	for _, role := range roles {
+		for _, p := range RolePermissions[role] {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// UserRole is an explicitly granted role.
+type UserRole struct {
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:uuid;primaryKey"`
+	Role      string     `json:"role" gorm:"primaryKey"`
+	GrantedBy *uuid.UUID `json:"granted_by" gorm:"type:uuid"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// LoadUserRoles returns the effective roles of a user: the implicit student
+// role, every granted role and admin for users carrying the legacy IsAdmin
+// flag.
+func LoadUserRoles(db *gorm.DB, user *User) ([]string, error) {
+	if user.IsGuest {
+		return []string{RoleGuest}, nil
+	}
+
+	var granted []string
+	if err := db.Model(&UserRole{}).Where("user_id = ?", user.ID).Order("role").Pluck("role", &granted).Error; err != nil {
+		return nil, err
+	}
+
+	roles := []string{RoleStudent}
+	hasAdmin := false
+	for _, role := range granted {
+		if role == RoleStudent {
+			continue
+		}
+		if role == RoleAdmin {
+			hasAdmin = true
+		}
+		roles = append(roles, role)
+	}
+	if user.IsAdmin && !hasAdmin {
+		roles = append(roles, RoleAdmin)
+	}
+
+	return roles, nil
+}
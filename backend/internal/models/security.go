@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Security event types.
+const (
+	SecurityEventLoginFailed    = "login_failed"
+	SecurityEventLoginThrottled = "login_throttled"
+	SecurityEventAccountLocked  = "account_locked"
+	SecurityEventIPLocked       = "ip_locked"
+	SecurityEventMFAFailed      = "mfa_failed"
+	SecurityEventMFAEnabled     = "mfa_enabled"
+	SecurityEventMFADisabled    = "mfa_disabled"
+	SecurityEventRecoveryUsed   = "mfa_recovery_code_used"
+	SecurityEventDataExported   = "data_exported"
+	SecurityEventDeletionQueued = "account_deletion_requested"
+	SecurityEventDeletionCancel = "account_deletion_cancelled"
+)
+
+// SecurityEvent is an audit record of a security relevant action.
+type SecurityEvent struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID    *uuid.UUID `json:"user_id" gorm:"type:uuid;index"`
+	Type      string     `json:"type" gorm:"not null;index"`
+	Email     string     `json:"email"`
+	IPAddress string     `json:"ip_address"`
+	UserAgent string     `json:"user_agent"`
+	Details   string     `json:"details"`
+	CreatedAt time.Time  `json:"created_at" gorm:"index"`
+}
+
+// LoginThrottle is the persisted failure history of one throttling key.
+type LoginThrottle struct {
+	Key           string     `json:"key" gorm:"primaryKey"`
+	Failures      int        `json:"failures" gorm:"not null;default:0"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	BlockedUntil  *time.Time `json:"blocked_until"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Friendship statuses.
+const (
+	FriendshipPending  = "pending"
+	FriendshipAccepted = "accepted"
+)
+
+// Friendship links two users. UserID sent the request to FriendID; once
+// accepted the relation is symmetric.
+type Friendship struct {
+	UserID     uuid.UUID  `json:"user_id" gorm:"type:uuid;primaryKey"`
+	FriendID   uuid.UUID  `json:"friend_id" gorm:"type:uuid;primaryKey;index"`
+	Status     string     `json:"status" gorm:"not null;default:'pending'"`
+	CreatedAt  time.Time  `json:"created_at"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+}
+
+// Classroom groups learners under a teacher. Learners join with JoinCode.
+type Classroom struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Name      string    `json:"name" gorm:"not null"`
+	OwnerID   uuid.UUID `json:"owner_id" gorm:"type:uuid;not null;index"`
+	JoinCode  string    `json:"join_code,omitempty" gorm:"not null;uniqueIndex"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ClassroomMember struct {
+	ClassroomID uuid.UUID `json:"classroom_id" gorm:"type:uuid;primaryKey"`
+	UserID      uuid.UUID `json:"user_id" gorm:"type:uuid;primaryKey;index"`
+	JoinedAt    time.Time `json:"joined_at" gorm:"not null;default:now()"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Daily goal types.
+const (
+	DailyGoalXP        = "xp"        // points scored in exercises
+	DailyGoalExercises = "exercises" // exercises attempted
+)
+
+// UserStreak tracks consecutive days on which the user met their daily goal.
+// Dates are calendar days in the user's time zone, formatted as 2006-01-02.
+type UserStreak struct {
+	UserID           uuid.UUID `json:"user_id" gorm:"type:uuid;primary_key"`
+	CurrentStreak    int       `json:"current_streak" gorm:"default:0"`
+	LongestStreak    int       `json:"longest_streak" gorm:"default:0"`
+	LastGoalDate     string    `json:"last_goal_date"`
+	FreezesAvailable int       `json:"freezes_available" gorm:"default:0"`
+	FreezesUsed      int       `json:"freezes_used" gorm:"default:0"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
@@ -8,17 +8,25 @@ import (
 )
 
 type User struct {
-	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	Email     string         `json:"email" gorm:"uniqueIndex;not null"`
-	Password  string         `json:"-" gorm:"not null"`
-	Name      string         `json:"name" gorm:"not null"`
-	Avatar    *string        `json:"avatar"`
-	Level     string         `json:"level" gorm:"default:'A0'"`
-	Points    int            `json:"points" gorm:"default:0"`
-	IsAdmin   bool           `json:"is_admin" gorm:"default:false"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID              uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Email           string         `json:"email" gorm:"uniqueIndex;not null"`
+	Password        string         `json:"-" gorm:"not null"`
+	Name            string         `json:"name" gorm:"not null"`
+	Avatar          *string        `json:"avatar"`
+	Level           string         `json:"level" gorm:"default:'A0'"`
+	Points          int            `json:"points" gorm:"default:0"`
+	IsAdmin         bool           `json:"is_admin" gorm:"default:false"`
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`
+	MFAEnabled      bool           `json:"mfa_enabled" gorm:"default:false"`
+	DeletionDueAt   *time.Time     `json:"deletion_due_at" gorm:"index"`
+	IsGuest         bool           `json:"is_guest" gorm:"default:false"`
+	GuestExpiresAt  *time.Time     `json:"guest_expires_at,omitempty" gorm:"index"`
+	TimeZone        string         `json:"time_zone" gorm:"default:'UTC'"`
+	DailyGoalType   string         `json:"daily_goal_type" gorm:"default:'xp'"`
+	DailyGoalTarget int            `json:"daily_goal_target" gorm:"default:20"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relations
 	Progress     []UserProgress `json:"progress,omitempty" gorm:"foreignKey:UserID"`
@@ -31,6 +39,7 @@ type UserProgress struct {
 	TopicID     uuid.UUID  `json:"topic_id" gorm:"type:uuid;not null"`
 	Completed   bool       `json:"completed" gorm:"default:false"`
 	Score       int        `json:"score" gorm:"default:0"`
+	TestedOut   bool       `json:"tested_out" gorm:"default:false"` // completed by a placement test
 	CompletedAt *time.Time `json:"completed_at"`
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
@@ -41,11 +50,33 @@ type UserProgress struct {
 }
 
 type Achievement struct {
-	ID          uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	Name        string    `json:"name" gorm:"not null"`
-	Description string    `json:"description"`
-	Icon        string    `json:"icon"`
-	Points      int       `json:"points" gorm:"default:0"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uuid.UUID            `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Code        *string              `json:"code" gorm:"uniqueIndex"`
+	Name        string               `json:"name" gorm:"not null"`
+	Description string               `json:"description"`
+	Icon        string               `json:"icon"`
+	Points      int                  `json:"points" gorm:"default:0"`
+	Criteria    *AchievementCriteria `json:"criteria" gorm:"type:jsonb;serializer:json"`
+	IsActive    bool                 `json:"is_active" gorm:"default:true"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+// AchievementCriteria declares when an achievement is granted: after Event
+// (any event when empty) the Metric, narrowed by the optional filters, must
+// reach Threshold. Achievements without criteria are never granted
+// automatically.
+type AchievementCriteria struct {
+	Event        string `json:"event,omitempty"`
+	Metric       string `json:"metric"`
+	Threshold    int    `json:"threshold"`
+	Level        string `json:"level,omitempty"`
+	ExerciseType string `json:"exercise_type,omitempty"`
+}
+
+// UserAchievement is the join table behind User.Achievements.
+type UserAchievement struct {
+	UserID        uuid.UUID `json:"user_id" gorm:"type:uuid;primaryKey"`
+	AchievementID uuid.UUID `json:"achievement_id" gorm:"type:uuid;primaryKey"`
+	GrantedAt     time.Time `json:"granted_at" gorm:"not null;default:now()"`
 }
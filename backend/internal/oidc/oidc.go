@@ -0,0 +1,50 @@
+package oidc
+
+import (
+	"english-learning-app/internal/config"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+)
+
+var ErrUnknownProvider = errors.New("unknown identity provider")
+
+var providers = map[string]*Provider{}
+
+// Setup registers every provider listed in the configuration. Discovery
+// documents are fetched lazily on first use.
+func Setup(cfg *config.Config) error {
+	registered := map[string]*Provider{}
+	for _, pc := range cfg.OIDC.Providers {
+		if pc.Issuer == "" || pc.ClientID == "" {
+			return fmt.Errorf("identity provider %q needs an issuer and a client ID", pc.Name)
+		}
+		registered[pc.Name] = NewProvider(pc)
+	}
+
+	providers = registered
+	if len(providers) > 0 {
+		log.Printf("Configured %d OpenID Connect provider(s)", len(providers))
+	}
+	return nil
+}
+
+// Get returns the provider registered under name.
+func Get(name string) (*Provider, error) {
+	p, ok := providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// List returns every registered provider ordered by name.
+func List() []*Provider {
+	list := make([]*Provider, 0, len(providers))
+	for _, p := range providers {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
@@ -0,0 +1,337 @@
+// Package placement runs adaptive placement tests. Questions are drawn from
+// the exercise bank and follow a one-up/one-down staircase over the levels:
+// a right answer moves the next question a level up, a wrong one a level
+// down. The test ends after enough direction changes, and the learner is
+// placed at the mean level of those turning points.
+package placement
+
+import (
+	"english-learning-app/internal/config"
+	"english-learning-app/internal/models"
+	"english-learning-app/internal/progression"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrAlreadyPlaced = errors.New("placement test already completed")
+	ErrNoQuestions   = errors.New("no placement questions available")
+	ErrNotInProgress = errors.New("placement test is not in progress")
+	ErrNotPending    = errors.New("exercise is not the pending question")
+)
+
+type Policy struct {
+	MaxQuestions  int
+	StopReversals int
+}
+
+func PolicyFromConfig(cfg *config.Config) Policy {
+	return Policy{
+		MaxQuestions:  cfg.Placement.MaxQuestions,
+		StopReversals: cfg.Placement.StopReversals,
+	}
+}
+
+// Result is what answering a question led to.
+type Result struct {
+	Test      *models.PlacementTest
+	IsCorrect bool
+	Finished  bool
+	LevelUp   *models.UserLevelHistory
+	TestedOut int // topics marked complete below the placed level
+}
+
+// levels returns the names of the active levels that have questions to ask,
+// lowest first.
+func levels(db *gorm.DB) ([]string, error) {
+	var names []string
+	err := db.Model(&models.Level{}).
+		Where("is_active AND EXISTS (?)", db.Model(&models.Exercise{}).
+			Select("1").
+			Joins("JOIN topics ON topics.id = exercises.topic_id").
+			Where("topics.level_id = levels.id AND topics.is_active AND exercises.is_active")).
+		Order(`"order"`).
+		Pluck("name", &names).Error
+	return names, err
+}
+
+func indexOf(levels []string, name string) int {
+	for i, level := range levels {
+		if level == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// staircase is the state of a test replayed from its answers.
+type staircase struct {
+	index     int   // level of the next question
+	reversals []int // levels at which the direction changed
+	last      int   // direction of the last step, +1 or -1
+}
+
+func replay(levels []string, start int, answers []models.PlacementAnswer) staircase {
+	s := staircase{index: start}
+	for _, answer := range answers {
+		at := indexOf(levels, answer.Level)
+		if at < 0 {
+			continue
+		}
+
+		step := -1
+		if answer.IsCorrect {
+			step = 1
+		}
+		next := at + step
+		switch {
+		case next < 0 || next >= len(levels):
+			// Pushing against the lowest or highest level counts as a
+			// turning point, otherwise the test could not end there
+			next = at
+			s.reversals = append(s.reversals, at)
+		case s.last != 0 && step != s.last:
+			s.reversals = append(s.reversals, at)
+		}
+		s.last = step
+		s.index = next
+	}
+	return s
+}
+
+// estimate returns the placed level: the mean of the turning points rounded
+// down, or the current level if there were none.
+func (s staircase) estimate() int {
+	if len(s.reversals) == 0 {
+		return s.index
+	}
+	sum := 0
+	for _, level := range s.reversals {
+		sum += level
+	}
+	return sum / len(s.reversals)
+}
+
+// pick draws a random active exercise of level the test has not asked yet.
+func pick(tx *gorm.DB, test *models.PlacementTest, level string) (*uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := tx.Model(&models.Exercise{}).
+		Joins("JOIN topics ON topics.id = exercises.topic_id").
+		Joins("JOIN levels ON levels.id = topics.level_id").
+		Where("levels.name = ? AND topics.is_active AND exercises.is_active", level).
+		Where("exercises.id NOT IN (?)", tx.Model(&models.PlacementAnswer{}).Select("exercise_id").Where("test_id = ?", test.ID)).
+		Order("RANDOM()").
+		Limit(1).
+		Pluck("exercises.id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	return &ids[0], nil
+}
+
+// Start resumes the user's unfinished test or starts one at the middle
+// level. Each learner is placed once.
+func Start(db *gorm.DB, userID uuid.UUID) (*models.PlacementTest, error) {
+	var test models.PlacementTest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the user so concurrent starts create one test
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", userID).First(&models.User{}).Error; err != nil {
+			return err
+		}
+
+		err := tx.Where("user_id = ?", userID).Order("started_at DESC").First(&test).Error
+		if err == nil {
+			if test.Status == models.PlacementCompleted {
+				return ErrAlreadyPlaced
+			}
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		names, err := levels(tx)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return ErrNoQuestions
+		}
+
+		test = models.PlacementTest{UserID: userID, CurrentLevel: names[len(names)/2]}
+		if err := tx.Create(&test).Error; err != nil {
+			return err
+		}
+		if test.ExerciseID, err = pick(tx, &test, test.CurrentLevel); err != nil {
+			return err
+		}
+		if test.ExerciseID == nil {
+			return ErrNoQuestions
+		}
+		return tx.Model(&test).Update("exercise_id", test.ExerciseID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &test, nil
+}
+
+// Answer records the answer to the pending question, graded by the caller,
+// and either moves on to the next question or finishes the test.
+func Answer(db *gorm.DB, testID, userID, exerciseID uuid.UUID, answer string, isCorrect bool, policy Policy) (*Result, error) {
+	result := &Result{IsCorrect: isCorrect}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var test models.PlacementTest
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", testID, userID).
+			First(&test).Error; err != nil {
+			return err
+		}
+		if test.Status != models.PlacementInProgress {
+			return ErrNotInProgress
+		}
+		if test.ExerciseID == nil || *test.ExerciseID != exerciseID {
+			return ErrNotPending
+		}
+		result.Test = &test
+
+		if err := tx.Create(&models.PlacementAnswer{
+			TestID:     test.ID,
+			ExerciseID: exerciseID,
+			Level:      test.CurrentLevel,
+			Answer:     answer,
+			IsCorrect:  isCorrect,
+			AnsweredAt: time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+
+		var answers []models.PlacementAnswer
+		if err := tx.Where("test_id = ?", test.ID).Order("answered_at, id").Find(&answers).Error; err != nil {
+			return err
+		}
+		names, err := levels(tx)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return ErrNoQuestions
+		}
+
+		start := indexOf(names, answers[0].Level)
+		if start < 0 {
+			start = len(names) / 2
+		}
+		s := replay(names, start, answers)
+
+		if len(s.reversals) < policy.StopReversals && len(answers) < policy.MaxQuestions {
+			next, err := pick(tx, &test, names[s.index])
+			if err != nil {
+				return err
+			}
+			if next != nil {
+				test.CurrentLevel, test.ExerciseID = names[s.index], next
+				return tx.Model(&test).Updates(map[string]interface{}{
+					"current_level": test.CurrentLevel,
+					"exercise_id":   test.ExerciseID,
+				}).Error
+			}
+			// The bank ran out of questions at this level, so go with what
+			// is known
+		}
+
+		result.Finished = true
+		return finish(tx, &test, names[s.estimate()], result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// finish completes the test, raises the user's level to the placed one and
+// marks every topic of the levels below as tested out. A placement never
+// lowers a level the learner already reached.
+func finish(tx *gorm.DB, test *models.PlacementTest, placed string, result *Result) error {
+	now := time.Now()
+	test.Status, test.ResultLevel, test.CompletedAt, test.ExerciseID = models.PlacementCompleted, &placed, &now, nil
+	if err := tx.Model(test).Updates(map[string]interface{}{
+		"status":       test.Status,
+		"result_level": placed,
+		"completed_at": now,
+		"exercise_id":  nil,
+	}).Error; err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", test.UserID).First(&user).Error; err != nil {
+		return err
+	}
+
+	var placedLevel models.Level
+	if err := tx.Where("name = ?", placed).First(&placedLevel).Error; err != nil {
+		return err
+	}
+	var currentOrder int
+	if err := tx.Model(&models.Level{}).Where("name = ?", user.Level).
+		Select(`COALESCE(MAX("order"), -1)`).Scan(&currentOrder).Error; err != nil {
+		return err
+	}
+	if placedLevel.Order <= currentOrder {
+		return nil
+	}
+
+	var err error
+	if result.LevelUp, err = progression.SetLevel(tx, &user, placed, models.LevelChangePlacement); err != nil {
+		return err
+	}
+
+	var topicIDs []uuid.UUID
+	if err := tx.Model(&models.Topic{}).
+		Joins("JOIN levels ON levels.id = topics.level_id").
+		Where(`topics.is_active AND levels."order" < ?`, placedLevel.Order).
+		Pluck("topics.id", &topicIDs).Error; err != nil {
+		return err
+	}
+
+	var progress []models.UserProgress
+	if err := tx.Where("user_id = ? AND topic_id IN ?", user.ID, topicIDs).Find(&progress).Error; err != nil {
+		return err
+	}
+	tracked := make(map[uuid.UUID]bool, len(progress))
+	for _, p := range progress {
+		tracked[p.TopicID] = true
+		if p.Completed {
+			continue
+		}
+		if err := tx.Model(&p).Updates(map[string]interface{}{
+			"completed":    true,
+			"tested_out":   true,
+			"completed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		result.TestedOut++
+	}
+	for _, topicID := range topicIDs {
+		if tracked[topicID] {
+			continue
+		}
+		if err := tx.Create(&models.UserProgress{
+			UserID:      user.ID,
+			TopicID:     topicID,
+			Completed:   true,
+			TestedOut:   true,
+			CompletedAt: &now,
+		}).Error; err != nil {
+			return err
+		}
+		result.TestedOut++
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+// Package points maintains the points ledger, the cached balance on
+// User.Points and the stored leaderboard scores. All changes to a user's
+// points go through Award.
+package points
+
+import (
+	"english-learning-app/internal/leaderboard"
+	"english-learning-app/internal/models"
+	"log"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Award appends entry to the ledger and adds its delta to the user's cached
+// balance and leaderboard scores in the same transaction. An entry whose
+// idempotency key was already recorded is ignored; applied reports whether
+// the entry was new.
+func Award(tx *gorm.DB, entry *models.PointsEntry) (applied bool, err error) {
+	err = tx.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "idempotency_key"}},
+			DoNothing: true,
+		}).Create(entry)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		applied = true
+		if err := tx.Model(&models.User{}).
+			Where("id = ?", entry.UserID).
+			Update("points", gorm.Expr("points + ?", entry.Delta)).Error; err != nil {
+			return err
+		}
+		return leaderboard.Record(tx, entry)
+	})
+	return applied, err
+}
+
+// Balance returns the sum of the user's ledger entries.
+func Balance(db *gorm.DB, userID uuid.UUID) (int, error) {
+	var balance int
+	err := db.Model(&models.PointsEntry{}).
+		Select("COALESCE(SUM(delta), 0)").
+		Where("user_id = ?", userID).
+		Scan(&balance).Error
+	return balance, err
+}
+
+// Recompute overwrites the cached balance and leaderboard scores with the
+// ledger sums and returns the balance.
+func Recompute(tx *gorm.DB, userID uuid.UUID) (int, error) {
+	var balance int
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		var err error
+		balance, err = Balance(tx, userID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("points", balance).Error; err != nil {
+			return err
+		}
+		return leaderboard.RebuildUser(tx, userID)
+	})
+	return balance, err
+}
+
+// Backfill gives every user with points but no ledger history an opening
+// balance entry, so the ledger matches balances earned before it existed.
+// Safe to run on every start.
+func Backfill(db *gorm.DB) error {
+	var users []models.User
+	if err := db.Where("points <> 0 AND NOT EXISTS (?)",
+		db.Model(&models.PointsEntry{}).Select("1").Where("points_entries.user_id = users.id"),
+	).Find(&users).Error; err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		entry := models.PointsEntry{
+			UserID:         user.ID,
+			SourceType:     models.PointsSourceOpeningBalance,
+			Delta:          user.Points,
+			IdempotencyKey: "opening_balance:" + user.ID.String(),
+			Reason:         "Balance before the points ledger",
+		}
+		// The balance is already on the user, so only the entry is written
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry).Error; err != nil {
+			return err
+		}
+	}
+
+	if len(users) > 0 {
+		log.Printf("Backfilled opening points balance for %d users", len(users))
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// Package progression moves learners up the CEFR levels once they meet the
+// promotion rules of their current level.
+package progression
+
+import (
+	"english-learning-app/internal/models"
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Stats is a learner's standing in one level.
+type Stats struct {
+	Level           string `json:"level"`
+	TopicsTotal     int    `json:"topics_total"`
+	TopicsCompleted int    `json:"topics_completed"`
+	TopicsPercent   int    `json:"topics_percent"`
+	AverageScore    int    `json:"average_score"`
+	// Rules of the level, copied so clients can show what is missing
+	MinTopicsPercent int  `json:"min_topics_percent"`
+	MinAverageScore  int  `json:"min_average_score"`
+	Eligible         bool `json:"eligible"`
+}
+
+// Measure computes the user's standing in level. Topic scores are taken as a
+// percentage of the topic's active exercise points.
+func Measure(db *gorm.DB, userID uuid.UUID, level *models.Level) (*Stats, error) {
+	var row struct {
+		TopicsTotal     int
+		TopicsCompleted int
+		AverageScore    float64
+	}
+	err := db.Raw(`SELECT
+			(SELECT COUNT(*) FROM topics WHERE level_id = ? AND is_active) AS topics_total,
+			COUNT(up.id) AS topics_completed,
+			COALESCE(AVG(CASE WHEN m.max_score > 0 THEN LEAST(100, up.score * 100.0 / m.max_score) ELSE 100 END), 0) AS average_score
+		FROM user_progresses up
+		JOIN topics t ON t.id = up.topic_id AND t.level_id = ? AND t.is_active
+		LEFT JOIN (SELECT topic_id, SUM(points) AS max_score FROM exercises WHERE is_active GROUP BY topic_id) m ON m.topic_id = t.id
+		WHERE up.user_id = ? AND up.completed`,
+		level.ID, level.ID, userID).Scan(&row).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{
+		Level:            level.Name,
+		TopicsTotal:      row.TopicsTotal,
+		TopicsCompleted:  row.TopicsCompleted,
+		AverageScore:     int(row.AverageScore),
+		MinTopicsPercent: level.MinTopicsPercent,
+		MinAverageScore:  level.MinAverageScore,
+	}
+	if stats.TopicsTotal > 0 {
+		stats.TopicsPercent = stats.TopicsCompleted * 100 / stats.TopicsTotal
+	}
+	// A level without content cannot be passed yet
+	stats.Eligible = stats.TopicsTotal > 0 &&
+		stats.TopicsPercent >= level.MinTopicsPercent &&
+		stats.AverageScore >= level.MinAverageScore
+	return stats, nil
+}
+
+// next returns the active level following level, or nil at the top.
+func next(db *gorm.DB, level *models.Level) (*models.Level, error) {
+	var following models.Level
+	err := db.Where(`is_active = ? AND "order" > ?`, true, level.Order).Order(`"order"`).First(&following).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &following, nil
+}
+
+// Evaluate promotes the user as far as the rules allow, one level at a time,
+// and returns the recorded level-ups. The caller should hold a lock on the
+// user row.
+func Evaluate(tx *gorm.DB, user *models.User) ([]models.UserLevelHistory, error) {
+	promotions := []models.UserLevelHistory{}
+	for {
+		var current models.Level
+		err := tx.Where("name = ?", user.Level).First(&current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return promotions, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		stats, err := Measure(tx, user.ID, &current)
+		if err != nil {
+			return nil, err
+		}
+		if !stats.Eligible {
+			return promotions, nil
+		}
+
+		following, err := next(tx, &current)
+		if err != nil {
+			return nil, err
+		}
+		if following == nil {
+			return promotions, nil
+		}
+
+		change, err := SetLevel(tx, user, following.Name, models.LevelChangePromotion)
+		if err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, *change)
+	}
+}
+
+// SetLevel moves the user to level and records the change in their history.
+func SetLevel(tx *gorm.DB, user *models.User, level, reason string) (*models.UserLevelHistory, error) {
+	change := models.UserLevelHistory{
+		UserID:    user.ID,
+		FromLevel: user.Level,
+		ToLevel:   level,
+		Reason:    reason,
+	}
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("level", level).Error; err != nil {
+			return err
+		}
+		return tx.Create(&change).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	user.Level = level
+	return &change, nil
+}
@@ -0,0 +1,193 @@
+// Package quiz runs quiz sessions: timed or untimed runs through a topic's
+// exercises in an order fixed when the session starts.
+package quiz
+
+import (
+	"english-learning-app/internal/models"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNoExercises = errors.New("topic has no exercises")
+	ErrNotActive   = errors.New("quiz session is not in progress")
+	ErrExpired     = errors.New("quiz session time limit exceeded")
+	ErrNotCurrent  = errors.New("exercise is not the current question")
+)
+
+// Start freezes the active exercises of topic, in their order or shuffled,
+// into a new session. A zero time limit means no limit.
+func Start(db *gorm.DB, userID, topicID uuid.UUID, timeLimit time.Duration, shuffle bool, now time.Time) (*models.QuizSession, error) {
+	var exercises []models.Exercise
+	if err := db.Where("topic_id = ? AND is_active = ?", topicID, true).Order(`"order"`).Find(&exercises).Error; err != nil {
+		return nil, err
+	}
+	if len(exercises) == 0 {
+		return nil, ErrNoExercises
+	}
+	if shuffle {
+		rand.Shuffle(len(exercises), func(i, j int) { exercises[i], exercises[j] = exercises[j], exercises[i] })
+	}
+
+	session := models.QuizSession{
+		UserID:    userID,
+		TopicID:   topicID,
+		Status:    models.QuizInProgress,
+		StartedAt: now,
+	}
+	if timeLimit > 0 {
+		seconds := int(timeLimit / time.Second)
+		expiresAt := now.Add(timeLimit)
+		session.TimeLimitSeconds, session.ExpiresAt = &seconds, &expiresAt
+	}
+	for i, exercise := range exercises {
+		session.Items = append(session.Items, models.QuizItem{
+			Position:   i + 1,
+			ExerciseID: exercise.ID,
+			Points:     exercise.Points,
+		})
+		session.MaxScore += exercise.Points
+	}
+
+	if err := db.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Current returns the first unanswered item of the session, or nil once
+// every question has been answered.
+func Current(session *models.QuizSession) *models.QuizItem {
+	for i := range session.Items {
+		if session.Items[i].AttemptID == nil {
+			return &session.Items[i]
+		}
+	}
+	return nil
+}
+
+// expired reports whether the session's time limit, plus grace, has passed.
+func expired(session *models.QuizSession, now time.Time, grace time.Duration) bool {
+	return session.ExpiresAt != nil && now.After(session.ExpiresAt.Add(grace))
+}
+
+// lock loads the user's session with its items and a row lock.
+func lock(tx *gorm.DB, sessionID, userID uuid.UUID) (*models.QuizSession, error) {
+	var session models.QuizSession
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Where("id = ? AND user_id = ?", sessionID, userID).
+		First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func finish(tx *gorm.DB, session *models.QuizSession, status string, now time.Time) error {
+	session.Status, session.FinishedAt = status, &now
+	return tx.Model(session).Updates(map[string]interface{}{
+		"status":      status,
+		"finished_at": now,
+	}).Error
+}
+
+// Load returns the user's session. A session whose time ran out is closed
+// as expired first.
+func Load(db *gorm.DB, sessionID, userID uuid.UUID, now time.Time, grace time.Duration) (*models.QuizSession, error) {
+	var session *models.QuizSession
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if session, err = lock(tx, sessionID, userID); err != nil {
+			return err
+		}
+		if session.Status == models.QuizInProgress && expired(session, now, grace) {
+			return finish(tx, session, models.QuizExpired, now)
+		}
+		return nil
+	})
+	return session, err
+}
+
+// Answer records attempt, graded by the caller, against the current question
+// of the session. Answering the last question finishes the session. Answers
+// arriving after the time limit close the session as expired instead.
+func Answer(db *gorm.DB, sessionID uuid.UUID, attempt *models.ExerciseAttempt, grace time.Duration) (*models.QuizSession, error) {
+	var session *models.QuizSession
+	var late bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if session, err = lock(tx, sessionID, attempt.UserID); err != nil {
+			return err
+		}
+		if session.Status != models.QuizInProgress {
+			return ErrNotActive
+		}
+		if expired(session, attempt.AttemptedAt, grace) {
+			late = true
+			return finish(tx, session, models.QuizExpired, attempt.AttemptedAt)
+		}
+
+		item := Current(session)
+		if item == nil || item.ExerciseID != attempt.ExerciseID {
+			return ErrNotCurrent
+		}
+
+		attempt.QuizSessionID = &session.ID
+		if err := tx.Create(attempt).Error; err != nil {
+			return err
+		}
+		item.AttemptID = &attempt.ID
+		if err := tx.Model(&models.QuizItem{}).
+			Where("session_id = ? AND position = ?", session.ID, item.Position).
+			Update("attempt_id", attempt.ID).Error; err != nil {
+			return err
+		}
+
+		// Points are those frozen at the start
+		score := attempt.Score
+		if score > item.Points {
+			score = item.Points
+		}
+		session.Score += score
+		if err := tx.Model(session).Update("score", session.Score).Error; err != nil {
+			return err
+		}
+
+		if Current(session) == nil {
+			return finish(tx, session, models.QuizFinished, attempt.AttemptedAt)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if late {
+		return session, ErrExpired
+	}
+	return session, nil
+}
+
+// Finish ends the session early; unanswered questions score nothing.
+func Finish(db *gorm.DB, sessionID, userID uuid.UUID, now time.Time, grace time.Duration) (*models.QuizSession, error) {
+	var session *models.QuizSession
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if session, err = lock(tx, sessionID, userID); err != nil {
+			return err
+		}
+		if session.Status != models.QuizInProgress {
+			return nil
+		}
+		status := models.QuizFinished
+		if expired(session, now, grace) {
+			status = models.QuizExpired
+		}
+		return finish(tx, session, status, now)
+	})
+	return session, err
+}
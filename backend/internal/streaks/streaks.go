@@ -0,0 +1,234 @@
+// Package streaks computes daily goal progress and maintains streaks of
+// consecutive days on which the goal was met. Days are calendar days in the
+// user's own time zone.
+package streaks
+
+import (
+	"english-learning-app/internal/models"
+	"time"
+
+	// Time zone data for hosts without a system zoneinfo database
+	_ "time/tzdata"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const dateLayout = "2006-01-02"
+
+// Status is the user's progress for today and the state of their streak.
+type Status struct {
+	Date            string `json:"date"`
+	TimeZone        string `json:"time_zone"`
+	GoalType        string `json:"goal_type"`
+	GoalTarget      int    `json:"goal_target"`
+	XP              int    `json:"xp"`
+	Exercises       int    `json:"exercises"`
+	TopicsCompleted int    `json:"topics_completed"`
+	GoalMet         bool   `json:"goal_met"`
+
+	CurrentStreak    int    `json:"current_streak"`
+	LongestStreak    int    `json:"longest_streak"`
+	FreezesAvailable int    `json:"freezes_available"`
+	LastGoalDate     string `json:"last_goal_date"`
+}
+
+// ValidTimeZone reports whether name is an IANA time zone name.
+func ValidTimeZone(name string) bool {
+	if name == "" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// ValidGoal reports whether the goal type is known and the target positive.
+func ValidGoal(goalType string, target int) bool {
+	return (goalType == models.DailyGoalXP || goalType == models.DailyGoalExercises) && target > 0
+}
+
+// location returns the user's time zone, falling back to UTC for unknown
+// names.
+func location(user *models.User) *time.Location {
+	if loc, err := time.LoadLocation(user.TimeZone); err == nil && user.TimeZone != "" {
+		return loc
+	}
+	return time.UTC
+}
+
+// Today returns the user's progress for the current day without changing
+// anything.
+func Today(db *gorm.DB, user *models.User, now time.Time) (*Status, error) {
+	status, err := today(db, user, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var streak models.UserStreak
+	if err := db.Where("user_id = ?", user.ID).Limit(1).Find(&streak).Error; err != nil {
+		return nil, err
+	}
+	status.apply(&streak)
+	return status, nil
+}
+
+// Record is called after learning activity. When the activity meets today's
+// goal for the first time the streak is extended, using freezes to bridge
+// missed days if there are enough. extended reports whether that happened.
+func Record(db *gorm.DB, user *models.User, now time.Time) (status *Status, extended bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		status, err = today(tx, user, now)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.UserStreak{UserID: user.ID}).Error; err != nil {
+			return err
+		}
+		var streak models.UserStreak
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", user.ID).First(&streak).Error; err != nil {
+			return err
+		}
+
+		// Dates compare in order as strings. An earlier one is possible after
+		// moving to a time zone behind the previous one and never extends
+		// the streak.
+		if status.GoalMet && streak.LastGoalDate < status.Date {
+			missed := missedDays(streak.LastGoalDate, status.Date)
+			switch {
+			case streak.LastGoalDate == "" || streak.CurrentStreak == 0:
+				streak.CurrentStreak = 1
+			case missed == 0:
+				streak.CurrentStreak++
+			case missed <= streak.FreezesAvailable:
+				streak.FreezesAvailable -= missed
+				streak.FreezesUsed += missed
+				streak.CurrentStreak++
+			default:
+				streak.CurrentStreak = 1
+			}
+			if streak.CurrentStreak > streak.LongestStreak {
+				streak.LongestStreak = streak.CurrentStreak
+			}
+			streak.LastGoalDate = status.Date
+
+			if err := tx.Save(&streak).Error; err != nil {
+				return err
+			}
+			extended = true
+		}
+
+		status.apply(&streak)
+		return nil
+	})
+	return status, extended, err
+}
+
+// BuyFreeze adds a freeze to the user's streak unless they already hold max.
+// The caller charges for it in the same transaction.
+func BuyFreeze(tx *gorm.DB, user *models.User, max int) (freezes int, ok bool, err error) {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.UserStreak{UserID: user.ID}).Error; err != nil {
+		return 0, false, err
+	}
+	var streak models.UserStreak
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", user.ID).First(&streak).Error; err != nil {
+		return 0, false, err
+	}
+	if streak.FreezesAvailable >= max {
+		return streak.FreezesAvailable, false, nil
+	}
+
+	streak.FreezesAvailable++
+	if err := tx.Model(&streak).Update("freezes_available", streak.FreezesAvailable).Error; err != nil {
+		return 0, false, err
+	}
+	return streak.FreezesAvailable, true, nil
+}
+
+// today computes the day's activity from exercise attempts and completed
+// topics recorded between the user's local midnights.
+func today(db *gorm.DB, user *models.User, now time.Time) (*Status, error) {
+	loc := location(user)
+	local := now.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	end := start.AddDate(0, 0, 1)
+
+	status := &Status{
+		Date:       start.Format(dateLayout),
+		TimeZone:   loc.String(),
+		GoalType:   user.DailyGoalType,
+		GoalTarget: user.DailyGoalTarget,
+	}
+	if !ValidGoal(status.GoalType, status.GoalTarget) {
+		status.GoalType, status.GoalTarget = models.DailyGoalXP, 20
+	}
+
+	var activity struct {
+		XP        int
+		Exercises int
+	}
+	// Each exercise counts once a day with its best score, so resubmitting a
+	// known answer does not add up
+	best := db.Model(&models.ExerciseAttempt{}).
+		Select("MAX(score) AS score").
+		Where("user_id = ? AND attempted_at >= ? AND attempted_at < ?", user.ID, start, end).
+		Group("exercise_id")
+	if err := db.Table("(?) AS day", best).
+		Select("COALESCE(SUM(score), 0) AS xp, COUNT(*) AS exercises").
+		Scan(&activity).Error; err != nil {
+		return nil, err
+	}
+	status.XP = activity.XP
+	status.Exercises = activity.Exercises
+
+	var topics int64
+	if err := db.Model(&models.UserProgress{}).
+		Where("user_id = ? AND completed_at >= ? AND completed_at < ?", user.ID, start, end).
+		Count(&topics).Error; err != nil {
+		return nil, err
+	}
+	status.TopicsCompleted = int(topics)
+
+	switch status.GoalType {
+	case models.DailyGoalExercises:
+		status.GoalMet = status.Exercises >= status.GoalTarget
+	default:
+		status.GoalMet = status.XP >= status.GoalTarget
+	}
+
+	return status, nil
+}
+
+// apply copies the streak into the status. A streak whose gap since the last
+// goal day cannot be covered by freezes is reported as broken even though it
+// is only reset the next time the goal is met.
+func (s *Status) apply(streak *models.UserStreak) {
+	s.LongestStreak = streak.LongestStreak
+	s.FreezesAvailable = streak.FreezesAvailable
+	s.LastGoalDate = streak.LastGoalDate
+
+	s.CurrentStreak = streak.CurrentStreak
+	if streak.LastGoalDate != "" && streak.LastGoalDate != s.Date {
+		// Today does not count as missed until it is over
+		if missedDays(streak.LastGoalDate, s.Date) > streak.FreezesAvailable {
+			s.CurrentStreak = 0
+		}
+	}
+}
+
+// missedDays returns the number of whole days strictly between from and to.
+func missedDays(from, to string) int {
+	start, err := time.Parse(dateLayout, from)
+	if err != nil {
+		return 0
+	}
+	end, err := time.Parse(dateLayout, to)
+	if err != nil {
+		return 0
+	}
+	days := int(end.Sub(start).Hours()/24) - 1
+	if days < 0 {
+		return 0
+	}
+	return days
+}
@@ -0,0 +1,72 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps throttling state in process memory. State is lost on
+// restart and not shared between instances.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]State
+	swept   time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]State)}
+}
+
+func (s *MemoryStore) Get(key string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[key], nil
+}
+
+func (s *MemoryStore) RecordFailure(key string, now time.Time, window time.Duration) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now, window)
+
+	state := s.entries[key]
+	if now.Sub(state.LastFailure) > window {
+		state.Failures = 0
+	}
+	state.Failures++
+	state.LastFailure = now
+	s.entries[key] = state
+
+	return state, nil
+}
+
+func (s *MemoryStore) Block(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.entries[key]
+	state.BlockedUntil = until
+	s.entries[key] = state
+	return nil
+}
+
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep drops stale entries at most once per window so the map does not grow
+// without bound. Callers must hold s.mu.
+func (s *MemoryStore) sweep(now time.Time, window time.Duration) {
+	if now.Sub(s.swept) < window {
+		return
+	}
+	for key, state := range s.entries {
+		if now.Sub(state.LastFailure) > window && !state.BlockedUntil.After(now) {
+			delete(s.entries, key)
+		}
+	}
+	s.swept = now
+}
@@ -0,0 +1,64 @@
+package throttle
+
+import (
+	"english-learning-app/internal/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostgresStore keeps throttling state in the login_throttles table so it is
+// shared between instances and survives restarts.
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Get(key string) (State, error) {
+	var row models.LoginThrottle
+	if err := s.db.Where("key = ?", key).First(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return State{}, nil
+		}
+		return State{}, err
+	}
+	return toState(row), nil
+}
+
+func (s *PostgresStore) RecordFailure(key string, now time.Time, window time.Duration) (State, error) {
+	var row models.LoginThrottle
+	err := s.db.Raw(`
+		INSERT INTO login_throttles (key, failures, last_failure_at, updated_at)
+		VALUES (?, 1, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at,
+			updated_at = EXCLUDED.updated_at
+		RETURNING key, failures, last_failure_at, blocked_until, updated_at`,
+		key, now, now, now.Add(-window),
+	).Scan(&row).Error
+	if err != nil {
+		return State{}, err
+	}
+	return toState(row), nil
+}
+
+func (s *PostgresStore) Block(key string, until time.Time) error {
+	return s.db.Model(&models.LoginThrottle{}).Where("key = ?", key).Update("blocked_until", until).Error
+}
+
+func (s *PostgresStore) Reset(key string) error {
+	return s.db.Where("key = ?", key).Delete(&models.LoginThrottle{}).Error
+}
+
+func toState(row models.LoginThrottle) State {
+	state := State{Failures: row.Failures, LastFailure: row.LastFailureAt}
+	if row.BlockedUntil != nil {
+		state.BlockedUntil = *row.BlockedUntil
+	}
+	return state
+}
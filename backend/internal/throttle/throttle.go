@@ -0,0 +1,165 @@
+package throttle
+
+import (
+	"english-learning-app/internal/config"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// State is the failure history tracked for a single key, such as an account
+// email or a client IP.
+type State struct {
+	Failures     int
+	LastFailure  time.Time
+	BlockedUntil time.Time
+}
+
+// Store persists throttling state. Implementations must make RecordFailure
+// atomic so concurrent failures are all counted.
+type Store interface {
+	Get(key string) (State, error)
+	// RecordFailure increments the failure counter of key. A counter whose
+	// last failure is older than window restarts from one.
+	RecordFailure(key string, now time.Time, window time.Duration) (State, error)
+	Block(key string, until time.Time) error
+	Reset(key string) error
+}
+
+// Policy controls how failures turn into delays and lockouts.
+type Policy struct {
+	// Failures allowed before any delay applies
+	FreeAttempts int
+	// Delay after the first failure beyond FreeAttempts; doubles with each
+	// further failure up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Failures after which the key is locked for LockoutDuration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// Failures older than Window are forgotten
+	Window time.Duration
+}
+
+type Limiter struct {
+	store  Store
+	policy Policy
+	prefix string
+}
+
+func NewLimiter(store Store, prefix string, policy Policy) *Limiter {
+	return &Limiter{store: store, policy: policy, prefix: prefix}
+}
+
+// Check returns how long the caller has to wait before the next attempt for
+// key is allowed. Zero means the attempt may proceed.
+func (l *Limiter) Check(key string) (time.Duration, error) {
+	state, err := l.store.Get(l.prefix + key)
+	if err != nil {
+		return 0, err
+	}
+	return l.wait(state, time.Now()), nil
+}
+
+// Fail records a failed attempt for key and reports whether it triggered a
+// lockout.
+func (l *Limiter) Fail(key string) (bool, error) {
+	now := time.Now()
+	state, err := l.store.RecordFailure(l.prefix+key, now, l.policy.Window)
+	if err != nil {
+		return false, err
+	}
+
+	if state.Failures >= l.policy.LockoutThreshold && !state.BlockedUntil.After(now) {
+		if err := l.store.Block(l.prefix+key, now.Add(l.policy.LockoutDuration)); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// Succeed clears the failure history of key.
+func (l *Limiter) Succeed(key string) error {
+	return l.store.Reset(l.prefix + key)
+}
+
+func (l *Limiter) wait(state State, now time.Time) time.Duration {
+	if state.BlockedUntil.After(now) {
+		return state.BlockedUntil.Sub(now)
+	}
+
+	excess := state.Failures - l.policy.FreeAttempts
+	if excess <= 0 || now.Sub(state.LastFailure) > l.policy.Window {
+		return 0
+	}
+
+	delay := l.policy.BaseDelay
+	for i := 1; i < excess && delay < l.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > l.policy.MaxDelay {
+		delay = l.policy.MaxDelay
+	}
+
+	if next := state.LastFailure.Add(delay); next.After(now) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// Limiters used by the login handler, set by Setup. Accounts are keyed by
+// normalized email, ClientIPs by remote address. The IP limits are looser
+// because whole classrooms often share one address. GuestSignups counts the
+// guest accounts created per remote address.
+var (
+	Accounts     *Limiter
+	ClientIPs    *Limiter
+	GuestSignups *Limiter
+)
+
+// Setup creates the login limiters on top of the configured store. db is only
+// used by the postgres store.
+func Setup(cfg *config.Config, db *gorm.DB) error {
+	var store Store
+	switch cfg.Throttle.Store {
+	case "memory":
+		store = NewMemoryStore()
+	case "postgres":
+		store = NewPostgresStore(db)
+	default:
+		return fmt.Errorf("unknown throttle store %q", cfg.Throttle.Store)
+	}
+
+	lockout := time.Duration(cfg.Throttle.LockoutMinutes) * time.Minute
+
+	Accounts = NewLimiter(store, "account:", Policy{
+		FreeAttempts:     3,
+		BaseDelay:        time.Second,
+		MaxDelay:         time.Minute,
+		LockoutThreshold: cfg.Throttle.MaxAccountFailures,
+		LockoutDuration:  lockout,
+		Window:           time.Hour,
+	})
+	ClientIPs = NewLimiter(store, "ip:", Policy{
+		FreeAttempts:     10,
+		BaseDelay:        time.Second,
+		MaxDelay:         30 * time.Second,
+		LockoutThreshold: cfg.Throttle.MaxIPFailures,
+		LockoutDuration:  lockout,
+		Window:           time.Hour,
+	})
+	GuestSignups = NewLimiter(store, "guest:", Policy{
+		FreeAttempts:     cfg.Account.MaxGuestsPerIP,
+		BaseDelay:        time.Minute,
+		MaxDelay:         time.Hour,
+		LockoutThreshold: 2 * cfg.Account.MaxGuestsPerIP,
+		LockoutDuration:  time.Hour,
+		Window:           time.Hour,
+	})
+
+	log.Printf("Login throttling configured with %s store", cfg.Throttle.Store)
+	return nil
+}
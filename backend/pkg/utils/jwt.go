@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -8,16 +10,30 @@ import (
 	"github.com/google/uuid"
 )
 
+// Token types carried in the "typ" claim so a refresh token can never be
+// presented where an access token is expected (and vice versa).
+const (
+	TokenTypeAccess     = "access"
+	TokenTypeRefresh    = "refresh"
+	TokenTypeMFAPending = "mfa_pending"
+)
+
 type Claims struct {
-	UserID uuid.UUID `json:"user_id"`
-	Email  string    `json:"email"`
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	Roles     []string  `json:"roles,omitempty"`
+	SessionID uuid.UUID `json:"sid"`
+	TokenType string    `json:"typ"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uuid.UUID, email, secretKey string, expiryMinutes int) (string, error) {
+func GenerateToken(userID, sessionID uuid.UUID, email string, roles []string, keys *KeySet, expiryMinutes int) (string, error) {
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		Roles:     roles,
+		SessionID: sessionID,
+		TokenType: TokenTypeAccess,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expiryMinutes) * time.Minute)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -25,31 +41,49 @@ func GenerateToken(userID uuid.UUID, email, secretKey string, expiryMinutes int)
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secretKey))
+	return keys.sign(claims)
 }
 
-func GenerateRefreshToken(userID uuid.UUID, secretKey string, expiryHours int) (string, error) {
+func GenerateRefreshToken(userID, sessionID uuid.UUID, keys *KeySet, expiryHours int) (string, error) {
 	claims := Claims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
+		TokenType: TokenTypeRefresh,
 		RegisteredClaims: jwt.RegisteredClaims{
+			// A unique ID keeps two tokens issued in the same second distinct,
+			// which matters because refresh tokens are stored by hash.
+			ID:        uuid.NewString(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expiryHours) * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secretKey))
+	return keys.sign(claims)
+}
+
+// GenerateMFAToken issues the short-lived token returned by a password login
+// when the account has two-factor authentication enabled. It only proves the
+// first factor and can be exchanged for real tokens with a valid code. The
+// token's ID is returned so the caller can make it single use.
+func GenerateMFAToken(userID uuid.UUID, keys *KeySet, expiryMinutes int) (string, string, error) {
+	claims := Claims{
+		UserID:    userID,
+		TokenType: TokenTypeMFAPending,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expiryMinutes) * time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token, err := keys.sign(claims)
+	return token, claims.ID, err
 }
 
-func ValidateToken(tokenString, secretKey string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(secretKey), nil
-	})
+func ValidateToken(tokenString string, keys *KeySet) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keys.keyFunc)
 
 	if err != nil {
 		return nil, err
@@ -61,3 +95,10 @@ func ValidateToken(tokenString, secretKey string) (*Claims, error) {
 
 	return nil, errors.New("invalid token")
 }
+
+// HashToken returns the hex-encoded SHA-256 of a token. Tokens are only ever
+// persisted in this form so a database leak does not leak usable credentials.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}